@@ -0,0 +1,368 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/2016114132/qod/internal/data"
+	"github.com/2016114132/qod/internal/validator"
+)
+
+func (a *application) createAuthorHandler(w http.ResponseWriter,
+	r *http.Request) {
+	var incomingData struct {
+		Name      string   `json:"name"`
+		Aliases   []string `json:"aliases"`
+		Bio       string   `json:"bio"`
+		BirthYear *int32   `json:"birth_year"`
+		DeathYear *int32   `json:"death_year"`
+	}
+	err := a.readJSON(w, r, &incomingData)
+	if err != nil {
+		a.badRequestResponse(w, r, err)
+		return
+	}
+
+	author := &data.Author{
+		Name:      incomingData.Name,
+		Slug:      data.Slugify(incomingData.Name),
+		Aliases:   incomingData.Aliases,
+		Bio:       incomingData.Bio,
+		BirthYear: incomingData.BirthYear,
+		DeathYear: incomingData.DeathYear,
+	}
+	// we want an empty list of aliases, not a missing one
+	if author.Aliases == nil {
+		author.Aliases = []string{}
+	}
+
+	v := validator.New()
+	data.ValidateAuthor(v, author)
+	if !v.IsEmpty() {
+		a.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	err = a.authorModel.Insert(author)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrDuplicateSlug):
+			v.AddError("name", "an author with this name already exists")
+			a.failedValidationResponse(w, r, v.Errors)
+		default:
+			a.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	headers := make(http.Header)
+	headers.Set("Location", fmt.Sprintf("/v1/authors/%d", author.ID))
+
+	data := envelope{
+		"author": author,
+	}
+	err = a.writeJSON(w, http.StatusCreated, data, headers)
+	if err != nil {
+		a.serverErrorResponse(w, r, err)
+	}
+}
+
+func (a *application) displayAuthorHandler(w http.ResponseWriter,
+	r *http.Request) {
+	id, err := a.readIDParam(r)
+	if err != nil {
+		a.notFoundResponse(w, r)
+		return
+	}
+	author, err := a.authorModel.Get(id)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			a.notFoundResponse(w, r)
+		default:
+			a.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	data := envelope{
+		"author": author,
+	}
+	err = a.writeJSON(w, http.StatusOK, data, nil)
+	if err != nil {
+		a.serverErrorResponse(w, r, err)
+	}
+}
+
+// A year in the body of a PATCH. A pointer alone can't tell a year that
+// was left out from one that is set to null to clear it, so set records
+// that the key was there
+type optionalYear struct {
+	set   bool
+	value *int32
+}
+
+func (y *optionalYear) UnmarshalJSON(b []byte) error {
+	y.set = true
+	return json.Unmarshal(b, &y.value)
+}
+
+func (a *application) updateAuthorHandler(w http.ResponseWriter,
+	r *http.Request) {
+	id, err := a.readIDParam(r)
+	if err != nil {
+		a.notFoundResponse(w, r)
+		return
+	}
+	author, err := a.authorModel.Get(id)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			a.notFoundResponse(w, r)
+		default:
+			a.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	// pointers so that we know which fields were left out, the years
+	// can also be cleared with a null
+	var incomingData struct {
+		Name      *string      `json:"name"`
+		Aliases   *[]string    `json:"aliases"`
+		Bio       *string      `json:"bio"`
+		BirthYear optionalYear `json:"birth_year"`
+		DeathYear optionalYear `json:"death_year"`
+	}
+	err = a.readJSON(w, r, &incomingData)
+	if err != nil {
+		a.badRequestResponse(w, r, err)
+		return
+	}
+	if incomingData.Name != nil {
+		author.Name = *incomingData.Name
+		author.Slug = data.Slugify(author.Name)
+	}
+	if incomingData.Aliases != nil {
+		author.Aliases = *incomingData.Aliases
+	}
+	if author.Aliases == nil {
+		author.Aliases = []string{}
+	}
+	if incomingData.Bio != nil {
+		author.Bio = *incomingData.Bio
+	}
+	if incomingData.BirthYear.set {
+		author.BirthYear = incomingData.BirthYear.value
+	}
+	if incomingData.DeathYear.set {
+		author.DeathYear = incomingData.DeathYear.value
+	}
+
+	v := validator.New()
+	data.ValidateAuthor(v, author)
+	if !v.IsEmpty() {
+		a.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	err = a.authorModel.Update(author, a.contextGetUser(r).ID)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrDuplicateSlug):
+			v.AddError("name", "an author with this name already exists")
+			a.failedValidationResponse(w, r, v.Errors)
+		case errors.Is(err, data.ErrEditConflict):
+			a.editConflictResponse(w, r)
+		default:
+			a.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	data := envelope{
+		"author": author,
+	}
+	err = a.writeJSON(w, http.StatusOK, data, nil)
+	if err != nil {
+		a.serverErrorResponse(w, r, err)
+	}
+}
+
+func (a *application) deleteAuthorHandler(w http.ResponseWriter,
+	r *http.Request) {
+	id, err := a.readIDParam(r)
+	if err != nil {
+		a.notFoundResponse(w, r)
+		return
+	}
+	err = a.authorModel.Delete(id)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			a.notFoundResponse(w, r)
+		default:
+			a.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	data := envelope{
+		"message": "author successfully deleted",
+	}
+	err = a.writeJSON(w, http.StatusOK, data, nil)
+	if err != nil {
+		a.serverErrorResponse(w, r, err)
+	}
+}
+
+func (a *application) listAuthorsHandler(w http.ResponseWriter,
+	r *http.Request) {
+	var queryParametersData struct {
+		Name string
+		data.Filters
+	}
+	queryParameters := r.URL.Query()
+
+	// search the names and the aliases
+	queryParametersData.Name = a.getSingleQueryParameter(queryParameters, "name", "")
+
+	v := validator.New()
+
+	queryParametersData.Filters.Page = a.getSingleIntegerParameter(
+		queryParameters, "page", 1, v)
+	queryParametersData.Filters.PageSize = a.getSingleIntegerParameter(
+		queryParameters, "page_size", 10, v)
+	queryParametersData.Filters.Sort = a.getSingleQueryParameter(
+		queryParameters, "sort", "name")
+	queryParametersData.Filters.SortSafeList = []string{"id", "name",
+		"-id", "-name"}
+
+	data.ValidateFilters(v, queryParametersData.Filters)
+	if !v.IsEmpty() {
+		a.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	authors, metadata, err := a.authorModel.GetAll(
+		queryParametersData.Name,
+		queryParametersData.Filters,
+	)
+	if err != nil {
+		a.serverErrorResponse(w, r, err)
+		return
+	}
+
+	data := envelope{
+		"authors":   authors,
+		"@metadata": metadata,
+	}
+	err = a.writeJSON(w, http.StatusOK, data, nil)
+	if err != nil {
+		a.serverErrorResponse(w, r, err)
+	}
+}
+
+// List the quotes of an author. It takes the same query parameters
+// as listQuotesHandler, the author is taken from the URL
+func (a *application) listAuthorQuotesHandler(w http.ResponseWriter,
+	r *http.Request) {
+	id, err := a.readIDParam(r)
+	if err != nil {
+		a.notFoundResponse(w, r)
+		return
+	}
+	// make sure the author exists so we can tell a missing author
+	// apart from an author without quotes
+	_, err = a.authorModel.Get(id)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			a.notFoundResponse(w, r)
+		default:
+			a.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	var queryParametersData struct {
+		data.QuoteFilters
+		data.Filters
+	}
+	queryParameters := r.URL.Query()
+
+	v := validator.New()
+
+	queryParametersData.QuoteFilters = a.getQuoteFilters(queryParameters, v)
+	queryParametersData.QuoteFilters.AuthorID = id
+
+	queryParametersData.Filters.Page = a.getSingleIntegerParameter(
+		queryParameters, "page", 1, v)
+	queryParametersData.Filters.PageSize = a.getSingleIntegerParameter(
+		queryParameters, "page_size", 10, v)
+	queryParametersData.Filters.Sort = a.getSingleQueryParameter(
+		queryParameters, "sort", "id")
+	queryParametersData.Filters.SortSafeList = []string{"id", "-id"}
+
+	data.ValidateFilters(v, queryParametersData.Filters)
+	if !v.IsEmpty() {
+		a.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	quotes, metadata, err := a.quoteModel.GetAll(
+		queryParametersData.QuoteFilters,
+		queryParametersData.Filters,
+	)
+	if err != nil {
+		a.serverErrorResponse(w, r, err)
+		return
+	}
+
+	data := envelope{
+		"quotes":    quotes,
+		"@metadata": metadata,
+	}
+	err = a.render(w, r, http.StatusOK, data, nil)
+	if err != nil {
+		a.serverErrorResponse(w, r, err)
+	}
+}
+
+// Autocomplete author names: GET /v1/authors/suggest?q=einst. Returns
+// the closest matches (limit, 5 by default) with their similarity scores.
+// An alias finds the author too
+func (a *application) suggestAuthorsHandler(w http.ResponseWriter,
+	r *http.Request) {
+	queryParameters := r.URL.Query()
+
+	v := validator.New()
+
+	search := a.getSingleQueryParameter(queryParameters, "q", "")
+	limit := a.getSingleIntegerParameter(queryParameters, "limit", 5, v)
+
+	v.Check(search != "", "q", "must be provided")
+	v.Check(len(search) <= 25, "q", "must not be more than 25 bytes long")
+	v.Check(limit > 0, "limit", "must be greater than zero")
+	v.Check(limit <= 20, "limit", "must be a maximum of 20")
+	if !v.IsEmpty() {
+		a.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	suggestions, err := a.authorModel.Suggest(search, limit)
+	if err != nil {
+		a.serverErrorResponse(w, r, err)
+		return
+	}
+
+	data := envelope{
+		"suggestions": suggestions,
+	}
+	err = a.writeJSON(w, http.StatusOK, data, nil)
+	if err != nil {
+		a.serverErrorResponse(w, r, err)
+	}
+}
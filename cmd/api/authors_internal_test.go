@@ -0,0 +1,37 @@
+// Filename: cmd/api/authors_internal_test.go
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"testing"
+)
+
+func TestOptionalYear(t *testing.T) {
+	tests := []struct {
+		body string
+		want string // set and value
+	}{
+		{`{}`, "false <nil>"},
+		{`{"birth_year": null}`, "true <nil>"},
+		{`{"birth_year": 1879}`, "true 1879"},
+	}
+
+	for _, tt := range tests {
+		var incomingData struct {
+			BirthYear optionalYear `json:"birth_year"`
+		}
+		err := json.Unmarshal([]byte(tt.body), &incomingData)
+		if err != nil {
+			t.Fatalf("%s: unexpected error: %v", tt.body, err)
+		}
+		got := fmt.Sprint(incomingData.BirthYear.set, " <nil>")
+		if incomingData.BirthYear.value != nil {
+			got = fmt.Sprint(incomingData.BirthYear.set, " ", *incomingData.BirthYear.value)
+		}
+		if got != tt.want {
+			t.Errorf("%s: expected: %q, got: %q", tt.body, tt.want, got)
+		}
+	}
+}
@@ -0,0 +1,113 @@
+package main
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// Our mobile clients poll the same resources over and over. If they send
+// back the ETag (If-None-Match) or the Last-Modified time (If-Modified-Since)
+// of the response they already have, and nothing changed, we send back a
+// 304 Not Modified without a body instead of the whole response again.
+// Just like render() the response is in the format of the Accept header
+func (a *application) renderConditional(w http.ResponseWriter,
+	r *http.Request,
+	data envelope,
+	headers http.Header,
+	lastModified time.Time) error {
+
+	format, ok := negotiateFormat(r.Header.Get("Accept"), formatsFor(data))
+	if !ok {
+		a.notAcceptableResponse(w, r)
+		return nil
+	}
+	body, err := encodeResponse(format, data)
+	if err != nil {
+		return err
+	}
+
+	if headers == nil {
+		headers = make(http.Header)
+	}
+	// the same URL answers differently depending on the Accept header
+	headers.Add("Vary", "Accept")
+	if etag := headers.Get("ETag"); etag != "" {
+		headers.Set("ETag", formatETag(etag, format))
+	}
+
+	return a.writeConditional(w, r, body, format.mediaType, headers, lastModified)
+}
+
+// Send the body unless the client already has it. If the headers don't
+// already contain an ETag, the ETag is a hash of the body. A zero
+// lastModified means we don't send Last-Modified. If-Match goes first,
+// like RFC 9110 says: a client that only wants the version it has gets
+// a 412 rather than another version, whatever If-None-Match says
+func (a *application) writeConditional(w http.ResponseWriter,
+	r *http.Request,
+	body []byte,
+	contentType string,
+	headers http.Header,
+	lastModified time.Time) error {
+
+	if headers == nil {
+		headers = make(http.Header)
+	}
+	// a weak ETag since the same data could be encoded differently
+	if headers.Get("ETag") == "" {
+		hash := sha256.Sum256(body)
+		headers.Set("ETag", fmt.Sprintf(`W/"%x"`, hash[:16]))
+	}
+	if !a.ifMatch(r, headers.Get("ETag")) {
+		a.preconditionFailedResponse(w, r)
+		return nil
+	}
+	if !lastModified.IsZero() {
+		headers.Set("Last-Modified", lastModified.UTC().Format(http.TimeFormat))
+	}
+	for key, value := range headers {
+		w.Header()[key] = value
+	}
+
+	if notModified(r, headers.Get("ETag"), lastModified) {
+		w.WriteHeader(http.StatusNotModified)
+		return nil
+	}
+
+	w.Header().Set("Content-Type", contentType)
+	w.WriteHeader(http.StatusOK)
+	_, err := w.Write(body)
+
+	return err
+}
+
+// Does the client already have the current version of the response?
+// If-None-Match wins over If-Modified-Since when both are sent
+func notModified(r *http.Request, etag string, lastModified time.Time) bool {
+	ifNoneMatch := r.Header.Get("If-None-Match")
+	if ifNoneMatch != "" {
+		for _, candidate := range strings.Split(ifNoneMatch, ",") {
+			candidate = strings.TrimSpace(candidate)
+			// If-None-Match uses the weak comparison so W/ is ignored
+			if candidate == "*" ||
+				strings.TrimPrefix(candidate, "W/") == strings.TrimPrefix(etag, "W/") {
+				return true
+			}
+		}
+		return false
+	}
+
+	ifModifiedSince := r.Header.Get("If-Modified-Since")
+	if ifModifiedSince != "" && !lastModified.IsZero() {
+		since, err := http.ParseTime(ifModifiedSince)
+		// HTTP dates have no fractions of a second
+		if err == nil && !lastModified.Truncate(time.Second).After(since) {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,94 @@
+// Filename: cmd/api/cache_internal_test.go
+
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestNotModified(t *testing.T) {
+	lastModified := time.Date(2025, time.March, 14, 12, 0, 0, 500, time.UTC)
+	before := lastModified.Add(-time.Hour).Format(http.TimeFormat)
+	same := lastModified.Format(http.TimeFormat)
+
+	tests := []struct {
+		name            string
+		etag            string
+		ifNoneMatch     string
+		ifModifiedSince string
+		want            bool
+	}{
+		{"no headers", `"3-json"`, "", "", false},
+		{"same tag", `"3-json"`, `"3-json"`, "", true},
+		{"other tag", `"3-json"`, `"2-json"`, "", false},
+		{"any tag", `"3-json"`, "*", "", true},
+		{"in a list", `"3-json"`, `"1-json", "2-json" ,"3-json"`, "", true},
+		{"not in a list", `"3-json"`, `"1-json", "2-json"`, "", false},
+		{"weak tag sent back", `W/"abc"`, `W/"abc"`, "", true},
+		{"weak against strong", `"abc"`, `W/"abc"`, "", true},
+		{"strong against weak", `W/"abc"`, `"abc"`, "", true},
+		{"not modified since", `"3-json"`, "", same, true},
+		{"modified since", `"3-json"`, "", before, false},
+		{"bad date", `"3-json"`, "", "yesterday", false},
+		{"tag wins over the date", `"3-json"`, `"2-json"`, same, false},
+		{"matching tag wins over an old date", `"3-json"`, `"3-json"`, before, true},
+	}
+
+	for _, tt := range tests {
+		r := httptest.NewRequest("GET", "/v1/quotes/1", nil)
+		if tt.ifNoneMatch != "" {
+			r.Header.Set("If-None-Match", tt.ifNoneMatch)
+		}
+		if tt.ifModifiedSince != "" {
+			r.Header.Set("If-Modified-Since", tt.ifModifiedSince)
+		}
+		got := notModified(r, tt.etag, lastModified)
+		if got != tt.want {
+			t.Errorf("%s: expected: %v, got: %v", tt.name, tt.want, got)
+		}
+	}
+}
+
+// If-Match is checked before If-None-Match
+func TestWriteConditional(t *testing.T) {
+	app := &application{}
+
+	tests := []struct {
+		name        string
+		etag        string
+		ifMatch     string
+		ifNoneMatch string
+		want        int
+	}{
+		{"no headers", `"3-json"`, "", "", http.StatusOK},
+		{"has it", `"3-json"`, "", `"3-json"`, http.StatusNotModified},
+		{"if-match passes", `"3-json"`, `"3-json"`, "", http.StatusOK},
+		{"if-match any", `"3-json"`, "*", `"3-json"`, http.StatusNotModified},
+		{"if-match fails first", `"3-json"`, `"2-json"`, `"3-json"`, http.StatusPreconditionFailed},
+		{"if-match on a weak tag", `W/"abc"`, `W/"abc"`, "", http.StatusPreconditionFailed},
+	}
+
+	for _, tt := range tests {
+		r := httptest.NewRequest("GET", "/v1/quotes/1", nil)
+		if tt.ifMatch != "" {
+			r.Header.Set("If-Match", tt.ifMatch)
+		}
+		if tt.ifNoneMatch != "" {
+			r.Header.Set("If-None-Match", tt.ifNoneMatch)
+		}
+		headers := make(http.Header)
+		headers.Set("ETag", tt.etag)
+		w := httptest.NewRecorder()
+
+		err := app.writeConditional(w, r, []byte(`{}`), "application/json", headers, time.Time{})
+		if err != nil {
+			t.Fatalf("%s: unexpected error: %v", tt.name, err)
+		}
+		if w.Code != tt.want {
+			t.Errorf("%s: expected: %d, got: %d", tt.name, tt.want, w.Code)
+		}
+	}
+}
@@ -0,0 +1,227 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strconv"
+	"syscall"
+	"time"
+
+	"github.com/2016114132/qod/internal/data"
+)
+
+const (
+	// how many times we try to send a delivery before we give up on it
+	webhookMaxAttempts = 8
+	// the first retry waits this long, every retry after it twice as long
+	webhookRetryDelay = 30 * time.Second
+	// how long a receiver has to answer
+	webhookTimeout = 10 * time.Second
+	// how many deliveries the worker takes at a time. They are sent one
+	// after the other so the lease must cover all of them
+	webhookBatchSize = 10
+	webhookLease     = webhookBatchSize*webhookTimeout + time.Minute
+	// how often the worker looks for deliveries that are due
+	webhookPollInterval = 5 * time.Second
+)
+
+// The client for the webhook receivers. A redirect is not followed, it
+// counts as a failed delivery. ValidateWebhook() only lets in URLs with
+// public addresses, but the name may resolve to another address later,
+// so the dialer checks every address it connects to. There is no proxy
+// since the proxy would connect for us
+func newWebhookClient() *http.Client {
+	dialer := &net.Dialer{
+		Timeout: webhookTimeout,
+		Control: webhookDialControl,
+	}
+	return &http.Client{
+		Timeout: webhookTimeout,
+		Transport: &http.Transport{
+			DialContext:         dialer.DialContext,
+			ForceAttemptHTTP2:   true,
+			MaxIdleConns:        100,
+			IdleConnTimeout:     90 * time.Second,
+			TLSHandshakeTimeout: webhookTimeout,
+		},
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+}
+
+// Refuse to connect to an address inside our network
+func webhookDialControl(network, address string, c syscall.RawConn) error {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+	ip := net.ParseIP(host)
+	if ip == nil || !data.PublicIP(ip) {
+		return fmt.Errorf("the address %s is not a public address", host)
+	}
+	return nil
+}
+
+// Queue the event for every webhook that wants it. The change that the
+// event is about is already saved, so if this fails we only log it
+func (a *application) queueWebhooks(r *http.Request, payload data.WebhookPayload) {
+	payload.OccurredAt = time.Now().UTC()
+
+	queued, err := a.deliveryModel.Queue(payload)
+	if err != nil {
+		a.logError(r, err)
+		return
+	}
+	if queued > 0 {
+		a.wakeWebhookWorker()
+	}
+}
+
+// Let the worker know that there are new deliveries so it does not wait
+// for its next look
+func (a *application) wakeWebhookWorker() {
+	select {
+	case a.webhookWake <- struct{}{}:
+	default:
+	}
+}
+
+// Send the deliveries that are due until done is closed. The deliveries
+// are kept in the database, so the ones that were not sent yet when we
+// stop are sent when we (or another instance of the API) start again.
+// Closing done also cuts short the delivery being sent, so that slow
+// receivers don't hold up the shutdown
+func (a *application) deliverWebhooks(done <-chan struct{}) {
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		<-done
+		cancel()
+	}()
+
+	a.wg.Add(1)
+	go func() {
+		defer a.wg.Done()
+
+		ticker := time.NewTicker(webhookPollInterval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ticker.C:
+			case <-a.webhookWake:
+			case <-done:
+				return
+			}
+
+			for {
+				deliveries, err := a.deliveryModel.ClaimDue(webhookBatchSize, webhookLease)
+				if err != nil {
+					a.logger.Error(err.Error())
+					break
+				}
+				for _, delivery := range deliveries {
+					a.sendDelivery(ctx, delivery)
+					// we are stopping. The deliveries that are left (and
+					// the one cut short) are not recorded, so they are
+					// sent again once their lease runs out
+					if ctx.Err() != nil {
+						return
+					}
+					err = a.deliveryModel.RecordAttempt(delivery)
+					if err != nil {
+						a.logger.Error(err.Error())
+					}
+				}
+				if len(deliveries) < webhookBatchSize {
+					break
+				}
+			}
+		}
+	}()
+}
+
+// POST the delivery to its webhook and note how it went. A 2xx response
+// is a success, anything else is tried again later until we run out of
+// attempts
+func (a *application) sendDelivery(ctx context.Context, delivery *data.WebhookDelivery) {
+	delivery.Attempts++
+	delivery.ResponseStatus = nil
+	delivery.LastError = ""
+
+	status, err := a.postDelivery(ctx, delivery)
+	if status != 0 {
+		delivery.ResponseStatus = &status
+	}
+
+	switch {
+	case err == nil:
+		delivery.Status = data.DeliverySucceeded
+		delivery.NextAttemptAt = nil
+	case delivery.Attempts >= webhookMaxAttempts:
+		delivery.Status = data.DeliveryFailed
+		delivery.LastError = err.Error()
+		delivery.NextAttemptAt = nil
+	default:
+		delivery.Status = data.DeliveryPending
+		delivery.LastError = err.Error()
+		next := time.Now().Add(webhookBackoff(delivery.Attempts))
+		delivery.NextAttemptAt = &next
+	}
+}
+
+func (a *application) postDelivery(ctx context.Context, delivery *data.WebhookDelivery) (int, error) {
+	ctx, cancel := context.WithTimeout(ctx, webhookTimeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, delivery.URL,
+		bytes.NewReader(delivery.Payload))
+	if err != nil {
+		return 0, err
+	}
+	timestamp := time.Now().Unix()
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "qod-webhooks/"+a.config.vrs)
+	req.Header.Set("X-QOD-Event", delivery.Event)
+	req.Header.Set("X-QOD-Delivery", strconv.FormatInt(delivery.ID, 10))
+	req.Header.Set("X-QOD-Timestamp", strconv.FormatInt(timestamp, 10))
+	req.Header.Set("X-QOD-Signature", signWebhook(delivery.Secret, timestamp, delivery.Payload))
+
+	resp, err := a.webhookClient.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	// read a bit of the body so that the connection can be reused
+	_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, 64*1024))
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return resp.StatusCode, fmt.Errorf("the receiver answered with %s", resp.Status)
+	}
+	return resp.StatusCode, nil
+}
+
+// The X-QOD-Signature header: the HMAC-SHA256 of "timestamp.body" with
+// the secret of the webhook. The timestamp is signed too so that an old
+// delivery can't be sent again by someone else
+func signWebhook(secret string, timestamp int64, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	fmt.Fprintf(mac, "%d.", timestamp)
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// How long to wait after the attempt before the next one
+func webhookBackoff(attempts int) time.Duration {
+	if attempts < 1 {
+		return webhookRetryDelay
+	}
+	return webhookRetryDelay << (attempts - 1)
+}
@@ -0,0 +1,48 @@
+package main
+
+import (
+	"net/http"
+
+	"github.com/2016114132/qod/internal/data"
+	"github.com/2016114132/qod/internal/validator"
+)
+
+// Report the quotes that were added more than once, for moderators to
+// clean up. With near=true it reports pairs of quotes that are almost
+// the same instead of exact copies
+func (a *application) listDuplicatesHandler(w http.ResponseWriter,
+	r *http.Request) {
+	var filters data.Filters
+	queryParameters := r.URL.Query()
+
+	v := validator.New()
+
+	near := a.getBoolParameter(queryParameters, "near", false, v)
+
+	filters.Page = a.getSingleIntegerParameter(queryParameters, "page", 1, v)
+	filters.PageSize = a.getSingleIntegerParameter(queryParameters, "page_size", 10, v)
+	// the closest duplicates always come first
+	filters.Sort = "-similarity"
+	filters.SortSafeList = []string{"-similarity"}
+
+	data.ValidateFilters(v, filters)
+	if !v.IsEmpty() {
+		a.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	duplicates, metadata, err := a.quoteModel.GetDuplicates(near, filters)
+	if err != nil {
+		a.serverErrorResponse(w, r, err)
+		return
+	}
+
+	data := envelope{
+		"duplicates": duplicates,
+		"@metadata":  metadata,
+	}
+	err = a.render(w, r, http.StatusOK, data, nil)
+	if err != nil {
+		a.serverErrorResponse(w, r, err)
+	}
+}
@@ -13,13 +13,20 @@ func (a *application) logError(r *http.Request, err error) {
 
 }
 
+// Errors are sent in the format the client asked for, like every other
+// response. When it accepts none of the formats that the error can be
+// written in we send JSON anyway rather than lose the error
 func (a *application) errorResponseJSON(w http.ResponseWriter,
 	r *http.Request,
 	status int,
 	message any) {
 
 	errorData := envelope{"error": message}
-	err := a.writeJSON(w, status, errorData, nil)
+	format, ok := negotiateFormat(r.Header.Get("Accept"), formatsFor(errorData))
+	if !ok {
+		format = formatJSON
+	}
+	err := a.writeFormat(w, format, status, errorData, nil)
 	if err != nil {
 		a.logError(r, err)
 		w.WriteHeader(500)
@@ -87,6 +94,41 @@ func (a *application) editConflictResponse(w http.ResponseWriter, r *http.Reques
 
 }
 
+// send a 409 when a new quote is a copy of one we already have. We
+// point to the existing quote so the client can use it instead
+func (a *application) duplicateQuoteResponse(w http.ResponseWriter, r *http.Request, existingID int64) {
+
+	message := envelope{
+		"message":           "this quote already exists",
+		"existing_quote_id": existingID,
+	}
+	a.errorResponseJSON(w, r, http.StatusConflict, message)
+}
+
+// send an error response if the If-Match header does not match the
+// current version of the resource (412 - Precondition Failed)
+func (a *application) preconditionFailedResponse(w http.ResponseWriter, r *http.Request) {
+
+	message := "the resource has been modified since you last fetched it, please fetch it again"
+	a.errorResponseJSON(w, r, http.StatusPreconditionFailed, message)
+}
+
+// send an error response if the response can't be written in any of
+// the formats in the Accept header (406 - Not Acceptable)
+func (a *application) notAcceptableResponse(w http.ResponseWriter, r *http.Request) {
+
+	message := "the requested resource is not available in any of the formats in the Accept header"
+	a.errorResponseJSON(w, r, http.StatusNotAcceptable, message)
+}
+
+// send an error response if we can't read the format of the
+// request body (415 - Unsupported Media Type)
+func (a *application) unsupportedMediaTypeResponse(w http.ResponseWriter, r *http.Request) {
+
+	message := "the Content-Type of the request body is not supported"
+	a.errorResponseJSON(w, r, http.StatusUnsupportedMediaType, message)
+}
+
 // Return a 401 status code
 func (a *application) invalidCredentialsResponse(w http.ResponseWriter, r *http.Request) {
 	message := "invalid authentication credentials"
@@ -0,0 +1,204 @@
+package main
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/2016114132/qod/internal/data"
+	"github.com/2016114132/qod/internal/validator"
+)
+
+// Writes the quotes of an export in one of the export formats
+type quoteExporter interface {
+	begin() error
+	write(quote *data.Quote) error
+	end() error
+}
+
+// the media type and the file extension of each export format
+var exportFormats = map[string][2]string{
+	"ndjson": {"application/x-ndjson", "ndjson"},
+	"csv":    {"text/csv; charset=utf-8", "csv"},
+	"json":   {"application/json", "json"},
+}
+
+// Send every quote that matches the search terms, for backups and the
+// data warehouse. It takes the same search terms and sort as
+// listQuotesHandler, without the paging, and a format: ndjson (one
+// quote per line, the default), csv or json. The quotes are written out
+// as they are read from the database, never all held at once
+func (a *application) exportQuotesHandler(w http.ResponseWriter,
+	r *http.Request) {
+	queryParameters := r.URL.Query()
+
+	v := validator.New()
+
+	quoteFilters := a.getQuoteFilters(queryParameters, v)
+
+	var filters data.Filters
+	filters.Sort = a.getQuoteSort(queryParameters, quoteFilters, v)
+	filters.SortSafeList = quoteSortSafeList
+	v.Check(validator.PermittedValue(filters.Sort, filters.SortSafeList...), "sort",
+		"invalid sort value")
+
+	format := a.getSingleQueryParameter(queryParameters, "format", "ndjson")
+	v.Check(validator.PermittedValue(format, "ndjson", "csv", "json"), "format",
+		"must be one of ndjson, csv or json")
+
+	if !v.IsEmpty() {
+		a.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	// a full export takes longer to send than the server timeout allows
+	controller := http.NewResponseController(w)
+	_ = controller.SetWriteDeadline(time.Now().Add(data.ExportTimeout))
+
+	buffer := bufio.NewWriter(w)
+	exporter := newQuoteExporter(format, buffer)
+
+	// the headers go out with the first quote. Until then we can still
+	// answer with an error
+	started := false
+	start := func() error {
+		started = true
+		w.Header().Set("Content-Type", exportFormats[format][0])
+		w.Header().Set("Content-Disposition",
+			`attachment; filename="quotes.`+exportFormats[format][1]+`"`)
+		return exporter.begin()
+	}
+
+	err := a.quoteModel.Export(quoteFilters, filters, func(quote *data.Quote) error {
+		if !started {
+			err := start()
+			if err != nil {
+				return err
+			}
+		}
+		return exporter.write(quote)
+	})
+	if err == nil && !started {
+		// there were no quotes, but the file must still be valid
+		err = start()
+	}
+	if err == nil {
+		err = exporter.end()
+	}
+	if err == nil {
+		err = buffer.Flush()
+	}
+
+	if err != nil {
+		if !started {
+			a.serverErrorResponse(w, r, err)
+			return
+		}
+		// part of the export is already on its way, all we can do is
+		// stop and make a note of it
+		a.logError(r, err)
+	}
+}
+
+func newQuoteExporter(format string, w io.Writer) quoteExporter {
+	switch format {
+	case "csv":
+		return &csvQuoteExporter{writer: csv.NewWriter(w)}
+	case "json":
+		return &jsonQuoteExporter{w: w}
+	default:
+		return &ndjsonQuoteExporter{encoder: json.NewEncoder(w)}
+	}
+}
+
+// One JSON quote per line
+type ndjsonQuoteExporter struct {
+	encoder *json.Encoder
+}
+
+func (e *ndjsonQuoteExporter) begin() error { return nil }
+
+func (e *ndjsonQuoteExporter) write(quote *data.Quote) error {
+	return e.encoder.Encode(quote)
+}
+
+func (e *ndjsonQuoteExporter) end() error { return nil }
+
+// The same envelope as the listing, {"quotes": [...]}, written a quote
+// at a time
+type jsonQuoteExporter struct {
+	w     io.Writer
+	count int
+}
+
+func (e *jsonQuoteExporter) begin() error {
+	_, err := io.WriteString(e.w, "{\"quotes\": [")
+	return err
+}
+
+func (e *jsonQuoteExporter) write(quote *data.Quote) error {
+	separator := ",\n"
+	if e.count == 0 {
+		separator = "\n"
+	}
+	e.count++
+
+	js, err := json.Marshal(quote)
+	if err != nil {
+		return err
+	}
+	_, err = io.WriteString(e.w, separator)
+	if err != nil {
+		return err
+	}
+	_, err = e.w.Write(js)
+	return err
+}
+
+func (e *jsonQuoteExporter) end() error {
+	_, err := io.WriteString(e.w, "\n]}\n")
+	return err
+}
+
+// A header row and then a row per quote. The tags are comma-separated
+// in a single column, just like the import expects them
+type csvQuoteExporter struct {
+	writer *csv.Writer
+}
+
+func (e *csvQuoteExporter) begin() error {
+	return e.writer.Write([]string{"id", "content", "author", "author_id", "tags",
+		"created_by", "created_at", "updated_at", "version"})
+}
+
+func (e *csvQuoteExporter) write(quote *data.Quote) error {
+	return e.writer.Write([]string{
+		strconv.FormatInt(quote.ID, 10),
+		quote.Content,
+		quote.Author,
+		formatOptionalID(quote.AuthorID),
+		strings.Join(quote.Tags, ","),
+		formatOptionalID(quote.CreatedBy),
+		quote.CreatedAt.Format(time.RFC3339),
+		quote.UpdatedAt.Format(time.RFC3339),
+		strconv.FormatInt(int64(quote.Version), 10),
+	})
+}
+
+func (e *csvQuoteExporter) end() error {
+	e.writer.Flush()
+	return e.writer.Error()
+}
+
+// an empty column when there is no id
+func formatOptionalID(id *int64) string {
+	if id == nil {
+		return ""
+	}
+	return strconv.FormatInt(*id, 10)
+}
@@ -0,0 +1,146 @@
+// Filename: cmd/api/export_internal_test.go
+
+package main
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/2016114132/qod/internal/data"
+)
+
+// quotes whose content needs quoting or escaping in every format
+func testExportQuotes() []*data.Quote {
+	authorID := int64(7)
+	created := time.Date(2025, time.March, 14, 12, 0, 0, 0, time.UTC)
+	return []*data.Quote{
+		{ID: 1, Content: `He said "do, or do not"`, Author: "Yoda", AuthorID: &authorID,
+			Tags: []string{"life", "wisdom"}, CreatedAt: created, UpdatedAt: created, Version: 1},
+		{ID: 2, Content: "Two lines,\nand a tab\there", Author: "O'Brien",
+			Tags: []string{}, CreatedAt: created, UpdatedAt: created, Version: 3},
+		{ID: 3, Content: `<b>Bold</b> & \ backslash, café ☕`, Author: "Zoë",
+			Tags: []string{"art"}, CreatedAt: created, UpdatedAt: created, Version: 2},
+	}
+}
+
+func exportTo(t *testing.T, format string) []byte {
+	var buffer bytes.Buffer
+	exporter := newQuoteExporter(format, &buffer)
+	err := exporter.begin()
+	if err != nil {
+		t.Fatalf("%s: unexpected error: %v", format, err)
+	}
+	for _, quote := range testExportQuotes() {
+		err = exporter.write(quote)
+		if err != nil {
+			t.Fatalf("%s: unexpected error: %v", format, err)
+		}
+	}
+	err = exporter.end()
+	if err != nil {
+		t.Fatalf("%s: unexpected error: %v", format, err)
+	}
+	return buffer.Bytes()
+}
+
+func TestCSVExport(t *testing.T) {
+	reader := csv.NewReader(bytes.NewReader(exportTo(t, "csv")))
+	records, err := reader.ReadAll()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	want := [][]string{
+		{"id", "content", "author", "author_id", "tags", "created_by", "created_at", "updated_at", "version"},
+		{"1", `He said "do, or do not"`, "Yoda", "7", "life,wisdom", "", "2025-03-14T12:00:00Z", "2025-03-14T12:00:00Z", "1"},
+		{"2", "Two lines,\nand a tab\there", "O'Brien", "", "", "", "2025-03-14T12:00:00Z", "2025-03-14T12:00:00Z", "3"},
+		{"3", `<b>Bold</b> & \ backslash, café ☕`, "Zoë", "", "art", "", "2025-03-14T12:00:00Z", "2025-03-14T12:00:00Z", "2"},
+	}
+	if len(records) != len(want) {
+		t.Fatalf("expected: %d rows, got: %d", len(want), len(records))
+	}
+	for i := range want {
+		if strings.Join(records[i], "|") != strings.Join(want[i], "|") {
+			t.Errorf("row %d: expected: %q, got: %q", i, want[i], records[i])
+		}
+	}
+}
+
+// A CSV export can be imported as it is
+func TestCSVExportImport(t *testing.T) {
+	app := &application{}
+	r := httptest.NewRequest("POST", "/v1/quotes/import", bytes.NewReader(exportTo(t, "csv")))
+	r.Header.Set("Content-Type", "text/csv")
+
+	rows, err := app.newImportReader(r)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	for _, quote := range testExportQuotes() {
+		row, err := rows.next()
+		if err != nil {
+			t.Fatalf("%d: unexpected error: %v", quote.ID, err)
+		}
+		if row.Content != quote.Content || row.Author != quote.Author ||
+			strings.Join(row.Tags, ",") != strings.Join(quote.Tags, ",") {
+			t.Errorf("%d: expected: %q %q %q, got: %q %q %q", quote.ID,
+				quote.Content, quote.Author, quote.Tags, row.Content, row.Author, row.Tags)
+		}
+	}
+	_, err = rows.next()
+	if !errors.Is(err, io.EOF) {
+		t.Errorf("expected: %v, got: %v", io.EOF, err)
+	}
+}
+
+func TestNDJSONExport(t *testing.T) {
+	body := exportTo(t, "ndjson")
+	lines := strings.Split(strings.TrimSuffix(string(body), "\n"), "\n")
+	quotes := testExportQuotes()
+	if len(lines) != len(quotes) {
+		t.Fatalf("expected: %d lines, got: %d", len(quotes), len(lines))
+	}
+	for i, line := range lines {
+		var got data.Quote
+		err := json.Unmarshal([]byte(line), &got)
+		if err != nil {
+			t.Fatalf("line %d: unexpected error: %v", i, err)
+		}
+		if got.ID != quotes[i].ID || got.Content != quotes[i].Content || got.Author != quotes[i].Author {
+			t.Errorf("line %d: expected: %q, got: %q", i, quotes[i].Content, got.Content)
+		}
+	}
+}
+
+func TestJSONExport(t *testing.T) {
+	for _, count := range []int{0, 3} {
+		var buffer bytes.Buffer
+		exporter := newQuoteExporter("json", &buffer)
+		_ = exporter.begin()
+		for _, quote := range testExportQuotes()[:count] {
+			_ = exporter.write(quote)
+		}
+		_ = exporter.end()
+
+		var got struct {
+			Quotes []data.Quote `json:"quotes"`
+		}
+		err := json.Unmarshal(buffer.Bytes(), &got)
+		if err != nil {
+			t.Fatalf("%d quotes: unexpected error: %v", count, err)
+		}
+		if len(got.Quotes) != count {
+			t.Errorf("expected: %d quotes, got: %d", count, len(got.Quotes))
+		}
+		if count > 0 && got.Quotes[2].Content != `<b>Bold</b> & \ backslash, café ☕` {
+			t.Errorf("expected: %q, got: %q", `<b>Bold</b> & \ backslash, café ☕`, got.Quotes[2].Content)
+		}
+	}
+}
@@ -0,0 +1,285 @@
+package main
+
+import (
+	"encoding/xml"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/2016114132/qod/internal/data"
+)
+
+// How many days of daily quotes and how many of the newest quotes
+// go into the feeds
+const (
+	feedDays   = 30
+	feedLatest = 20
+)
+
+// An entry of a feed, whatever the feed format
+type feedEntry struct {
+	title     string
+	author    string
+	content   string
+	link      string // also the id of the entry
+	published time.Time
+	updated   time.Time
+}
+
+// RSS 2.0
+type rssFeed struct {
+	XMLName xml.Name   `xml:"rss"`
+	Version string     `xml:"version,attr"`
+	Channel rssChannel `xml:"channel"`
+}
+
+type rssChannel struct {
+	Title         string    `xml:"title"`
+	Link          string    `xml:"link"`
+	Description   string    `xml:"description"`
+	LastBuildDate string    `xml:"lastBuildDate"`
+	Items         []rssItem `xml:"item"`
+}
+
+type rssItem struct {
+	Title       string  `xml:"title"`
+	Link        string  `xml:"link"`
+	Description string  `xml:"description"`
+	GUID        rssGUID `xml:"guid"`
+	PubDate     string  `xml:"pubDate"`
+}
+
+type rssGUID struct {
+	IsPermaLink bool   `xml:"isPermaLink,attr"`
+	Value       string `xml:",chardata"`
+}
+
+// Atom (RFC 4287)
+type atomFeed struct {
+	XMLName xml.Name    `xml:"http://www.w3.org/2005/Atom feed"`
+	ID      string      `xml:"id"`
+	Title   string      `xml:"title"`
+	Updated string      `xml:"updated"`
+	Links   []atomLink  `xml:"link"`
+	Entries []atomEntry `xml:"entry"`
+}
+
+type atomLink struct {
+	Rel  string `xml:"rel,attr,omitempty"`
+	Href string `xml:"href,attr"`
+}
+
+type atomEntry struct {
+	ID        string      `xml:"id"`
+	Title     string      `xml:"title"`
+	Updated   string      `xml:"updated"`
+	Published string      `xml:"published"`
+	Author    atomAuthor  `xml:"author"`
+	Link      atomLink    `xml:"link"`
+	Content   atomContent `xml:"content"`
+}
+
+type atomAuthor struct {
+	Name string `xml:"name"`
+}
+
+type atomContent struct {
+	Type  string `xml:"type,attr"`
+	Value string `xml:",chardata"`
+}
+
+// The quote of the day for each of the last feedDays days as RSS
+func (a *application) dailyRSSHandler(w http.ResponseWriter, r *http.Request) {
+	entries, err := a.dailyFeedEntries()
+	if err != nil {
+		a.serverErrorResponse(w, r, err)
+		return
+	}
+	feed := a.dailyRSSFeed(entries)
+
+	a.writeFeed(w, r, feed, "application/rss+xml; charset=utf-8")
+}
+
+// The daily quotes as an RSS channel
+func (a *application) dailyRSSFeed(entries []feedEntry) rssFeed {
+	base := a.config.baseURL
+	updated := feedUpdated(entries)
+	feed := rssFeed{
+		Version: "2.0",
+		Channel: rssChannel{
+			Title:         "Quote of the Day",
+			Link:          base + "/v1/quotes/today",
+			Description:   "A new quote every day",
+			LastBuildDate: updated.Format(time.RFC1123Z),
+			Items:         []rssItem{},
+		},
+	}
+	for _, entry := range entries {
+		feed.Channel.Items = append(feed.Channel.Items, rssItem{
+			Title:       entry.title,
+			Link:        entry.link,
+			Description: entry.content,
+			GUID:        rssGUID{IsPermaLink: true, Value: entry.link},
+			PubDate:     entry.published.Format(time.RFC1123Z),
+		})
+	}
+	return feed
+}
+
+// The quote of the day for each of the last feedDays days as Atom
+func (a *application) dailyAtomHandler(w http.ResponseWriter, r *http.Request) {
+	entries, err := a.dailyFeedEntries()
+	if err != nil {
+		a.serverErrorResponse(w, r, err)
+		return
+	}
+	feed := a.atomFeed("/v1/feeds/daily.atom", "/v1/quotes/today",
+		"Quote of the Day", entries)
+
+	a.writeFeed(w, r, feed, "application/atom+xml; charset=utf-8")
+}
+
+// The newest quotes as Atom
+func (a *application) latestAtomHandler(w http.ResponseWriter, r *http.Request) {
+	filters := data.Filters{
+		Page:         1,
+		PageSize:     feedLatest,
+		Sort:         "-created_at",
+		SortSafeList: []string{"-created_at"},
+	}
+	quotes, _, err := a.quoteModel.GetAll(data.QuoteFilters{}, filters)
+	if err != nil {
+		a.serverErrorResponse(w, r, err)
+		return
+	}
+
+	base := a.config.baseURL
+	entries := []feedEntry{}
+	for _, quote := range quotes {
+		entries = append(entries, feedEntry{
+			title:     quote.Author,
+			author:    quote.Author,
+			content:   quote.Content,
+			link:      fmt.Sprintf("%s/v1/quotes/%d", base, quote.ID),
+			published: quote.CreatedAt,
+			updated:   quote.UpdatedAt,
+		})
+	}
+	feed := a.atomFeed("/v1/feeds/latest.atom", "/v1/quotes?sort=-created_at",
+		"Latest Quotes", entries)
+
+	a.writeFeed(w, r, feed, "application/atom+xml; charset=utf-8")
+}
+
+// The quote of the day for today and the days before it, newest first
+func (a *application) dailyFeedEntries() ([]feedEntry, error) {
+	base := a.config.baseURL
+	now := time.Now().UTC()
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+
+	days := make([]time.Time, feedDays)
+	for i := range days {
+		days[i] = today.AddDate(0, 0, -i)
+	}
+	quotes, err := a.quotesForDays(days)
+	if err != nil {
+		return nil, err
+	}
+
+	entries := []feedEntry{}
+	for _, day := range days {
+		quote, found := quotes[day.Format("2006-01-02")]
+		// no quotes at all means an empty feed
+		if !found {
+			break
+		}
+
+		// just like todayQuoteHandler, an entry is no older than its day
+		updated := quote.UpdatedAt
+		if day.After(updated) {
+			updated = day
+		}
+		date := day.Format("2006-01-02")
+		entries = append(entries, feedEntry{
+			title:     fmt.Sprintf("%s: %s", date, quote.Author),
+			author:    quote.Author,
+			content:   quote.Content,
+			link:      base + "/v1/quotes/today?date=" + date,
+			published: day,
+			updated:   updated,
+		})
+	}
+	return entries, nil
+}
+
+// The feed was last updated when its most recently updated entry was.
+// An empty feed was never updated, so we use the start of the Unix epoch
+func feedUpdated(entries []feedEntry) time.Time {
+	updated := time.Unix(0, 0).UTC()
+	for _, entry := range entries {
+		if entry.updated.After(updated) {
+			updated = entry.updated
+		}
+	}
+	return updated.UTC()
+}
+
+// An Atom feed at path. alternate is where the same quotes are in the API
+func (a *application) atomFeed(path string,
+	alternate string,
+	title string,
+	entries []feedEntry) atomFeed {
+
+	base := a.config.baseURL
+	feed := atomFeed{
+		ID:      base + path,
+		Title:   title,
+		Updated: feedUpdated(entries).Format(time.RFC3339),
+		Links: []atomLink{
+			{Rel: "self", Href: base + path},
+			{Rel: "alternate", Href: base + alternate},
+		},
+		Entries: []atomEntry{},
+	}
+	for _, entry := range entries {
+		feed.Entries = append(feed.Entries, atomEntry{
+			ID:        entry.link,
+			Title:     entry.title,
+			Updated:   entry.updated.UTC().Format(time.RFC3339),
+			Published: entry.published.UTC().Format(time.RFC3339),
+			Author:    atomAuthor{Name: entry.author},
+			Link:      atomLink{Rel: "alternate", Href: entry.link},
+			Content:   atomContent{Type: "text", Value: entry.content},
+		})
+	}
+	return feed
+}
+
+// Send the feed, or a 304 if the reader already has it. Feeds are public
+// since feed readers can't log in, and shared caches may keep them. That
+// is why the links come from the base-url setting and not from the Host
+// header of the request, which anyone can set
+func (a *application) writeFeed(w http.ResponseWriter,
+	r *http.Request,
+	feed any,
+	contentType string) {
+
+	body, err := xml.MarshalIndent(feed, "", "\t")
+	if err != nil {
+		a.serverErrorResponse(w, r, err)
+		return
+	}
+	body = append([]byte(xml.Header), body...)
+	body = append(body, '\n')
+
+	headers := make(http.Header)
+	headers.Set("Cache-Control", "public, max-age=300")
+
+	// no Last-Modified: a quote that leaves the feed, or a day whose
+	// quote changes, doesn't make the feed any newer. The ETag (a hash
+	// of the feed) changes whenever the feed does
+	err = a.writeConditional(w, r, body, contentType, headers, time.Time{})
+	if err != nil {
+		a.serverErrorResponse(w, r, err)
+	}
+}
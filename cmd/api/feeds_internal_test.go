@@ -0,0 +1,124 @@
+// Filename: cmd/api/feeds_internal_test.go
+
+package main
+
+import (
+	"encoding/xml"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// entries with characters that must be escaped in XML
+func testFeedEntries() []feedEntry {
+	day := time.Date(2025, time.March, 14, 0, 0, 0, 0, time.UTC)
+	return []feedEntry{
+		{
+			title:     "2025-03-14: Tom & Jerry",
+			author:    "Tom & Jerry",
+			content:   `<script>alert("hi")</script> & more`,
+			link:      "https://quotes.example.com/v1/quotes/today?date=2025-03-14",
+			published: day,
+			updated:   day.Add(2 * time.Hour),
+		},
+		{
+			title:     "2025-03-13: Yoda",
+			author:    "Yoda",
+			content:   "Do or do not",
+			link:      "https://quotes.example.com/v1/quotes/today?date=2025-03-13",
+			published: day.AddDate(0, 0, -1),
+			updated:   day.AddDate(0, 0, -1),
+		},
+	}
+}
+
+func TestAtomFeed(t *testing.T) {
+	app := &application{}
+	app.config.baseURL = "https://quotes.example.com"
+
+	r := httptest.NewRequest("GET", "/v1/feeds/daily.atom", nil)
+	// a spoofed host must not end up in the links
+	r.Host = "evil.example.com"
+	r.Header.Set("X-Forwarded-Proto", "http")
+	w := httptest.NewRecorder()
+	feed := app.atomFeed("/v1/feeds/daily.atom", "/v1/quotes/today",
+		"Quote of the Day", testFeedEntries())
+	app.writeFeed(w, r, feed, "application/atom+xml; charset=utf-8")
+
+	body := w.Body.String()
+	if !strings.HasPrefix(body, xml.Header) {
+		t.Errorf("expected the XML header, got: %q", body[:min(len(body), 40)])
+	}
+	if strings.Contains(body, "<script>") || strings.Contains(body, "evil.example.com") {
+		t.Errorf("expected escaped content and our own links, got: %s", body)
+	}
+
+	var got atomFeed
+	err := xml.Unmarshal(w.Body.Bytes(), &got)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	tests := []struct {
+		name string
+		got  string
+		want string
+	}{
+		{"id", got.ID, "https://quotes.example.com/v1/feeds/daily.atom"},
+		{"self", got.Links[0].Href, "https://quotes.example.com/v1/feeds/daily.atom"},
+		{"alternate", got.Links[1].Href, "https://quotes.example.com/v1/quotes/today"},
+		{"updated", got.Updated, "2025-03-14T02:00:00Z"},
+		{"entry id", got.Entries[0].ID, "https://quotes.example.com/v1/quotes/today?date=2025-03-14"},
+		{"entry author", got.Entries[0].Author.Name, "Tom & Jerry"},
+		{"entry content", got.Entries[0].Content.Value, `<script>alert("hi")</script> & more`},
+		{"entry published", got.Entries[1].Published, "2025-03-13T00:00:00Z"},
+	}
+	for _, tt := range tests {
+		if tt.got != tt.want {
+			t.Errorf("%s: expected: %q, got: %q", tt.name, tt.want, tt.got)
+		}
+	}
+	if len(got.Entries) != 2 {
+		t.Errorf("entries: expected: %d, got: %d", 2, len(got.Entries))
+	}
+}
+
+func TestRSSFeed(t *testing.T) {
+	app := &application{}
+	app.config.baseURL = "https://quotes.example.com"
+
+	r := httptest.NewRequest("GET", "/v1/feeds/daily.rss", nil)
+	w := httptest.NewRecorder()
+	app.writeFeed(w, r, app.dailyRSSFeed(testFeedEntries()), "application/rss+xml; charset=utf-8")
+
+	if w.Header().Get("Content-Type") != "application/rss+xml; charset=utf-8" {
+		t.Errorf("expected: %q, got: %q", "application/rss+xml; charset=utf-8",
+			w.Header().Get("Content-Type"))
+	}
+	var got rssFeed
+	err := xml.Unmarshal(w.Body.Bytes(), &got)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	tests := []struct {
+		name string
+		got  string
+		want string
+	}{
+		{"version", got.Version, "2.0"},
+		{"link", got.Channel.Link, "https://quotes.example.com/v1/quotes/today"},
+		{"last build", got.Channel.LastBuildDate, "Fri, 14 Mar 2025 02:00:00 +0000"},
+		{"item title", got.Channel.Items[0].Title, "2025-03-14: Tom & Jerry"},
+		{"item description", got.Channel.Items[0].Description, `<script>alert("hi")</script> & more`},
+		{"item guid", got.Channel.Items[0].GUID.Value, "https://quotes.example.com/v1/quotes/today?date=2025-03-14"},
+		{"item date", got.Channel.Items[1].PubDate, "Thu, 13 Mar 2025 00:00:00 +0000"},
+	}
+	for _, tt := range tests {
+		if tt.got != tt.want {
+			t.Errorf("%s: expected: %q, got: %q", tt.name, tt.want, tt.got)
+		}
+	}
+	if !got.Channel.Items[0].GUID.IsPermaLink {
+		t.Errorf("guid: expected a permalink")
+	}
+}
@@ -0,0 +1,854 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	"github.com/2016114132/qod/internal/data"
+	"github.com/2016114132/qod/internal/validator"
+	"github.com/graph-gophers/graphql-go"
+)
+
+// The GraphQL schema. It exposes the same quotes, authors and checks as
+// the REST routes, so a client can get a quote, its author and what the
+// user may do in one request
+const graphqlSchemaDefinition = `
+	schema {
+		query: Query
+		mutation: Mutation
+	}
+
+	scalar Time
+
+	type Query {
+		# The quote with the id, null if there is none. Needs quotes:read
+		quote(id: ID!): Quote
+		# The quotes, with the same filters, sorting and paging as
+		# GET /v1/quotes. Needs quotes:read
+		quotes(
+			content: String
+			author: String
+			authorId: ID
+			tags: [String!]
+			tagsMode: String
+			createdAfter: String
+			createdBefore: String
+			authorExact: String
+			minLength: Int
+			maxLength: Int
+			fuzzy: Boolean
+			page: Int
+			pageSize: Int
+			sort: String
+			cursor: String
+		): QuoteList!
+		# The author with the id, null if there is none. Needs quotes:read
+		author(id: ID!): Author
+		# The user making the request
+		me: User!
+	}
+
+	type Mutation {
+		# Needs quotes:write, and quotes:moderate to allow a duplicate
+		createQuote(input: QuoteInput!, allowDuplicate: Boolean): Quote!
+		# Needs quotes:write and the quote must be yours (or you a
+		# moderator). With a version the update fails if the quote has
+		# changed since
+		updateQuote(id: ID!, input: QuoteUpdate!, version: Int): Quote!
+		# Moves the quote to the trash. Same rules as updateQuote
+		deleteQuote(id: ID!): ID!
+	}
+
+	type Quote {
+		id: ID!
+		content: String!
+		author: String!
+		authorId: ID
+		# The author of the quote, null if the author is not known to us
+		authorProfile: Author
+		tags: [String!]!
+		createdBy: ID
+		createdAt: Time!
+		updatedAt: Time!
+		version: Int!
+	}
+
+	type QuoteList {
+		quotes: [Quote!]!
+		metadata: Metadata!
+	}
+
+	type Metadata {
+		currentPage: Int
+		pageSize: Int
+		firstPage: Int
+		lastPage: Int
+		totalRecords: Int
+		nextCursor: String
+	}
+
+	type Author {
+		id: ID!
+		name: String!
+		slug: String!
+		aliases: [String!]!
+		bio: String!
+		birthYear: Int
+		deathYear: Int
+		version: Int!
+	}
+
+	type User {
+		id: ID!
+		username: String!
+		email: String!
+		activated: Boolean!
+		createdAt: Time!
+		permissions: [String!]!
+	}
+
+	input QuoteInput {
+		content: String!
+		author: String
+		authorId: ID
+		tags: [String!]
+	}
+
+	input QuoteUpdate {
+		content: String
+		author: String
+		authorId: ID
+		tags: [String!]
+	}
+`
+
+// The longest query we run (10KB). Together with the depth and the
+// number of top-level fields it bounds the work of a request
+const graphqlMaxQueryLength = 10_000
+
+// How many top-level fields (quote, quotes, author, the mutations) a
+// request may resolve. Aliases let a client ask for the same field
+// over and over, and each of them goes to the database
+const graphqlMaxRootFields = 20
+
+// Parse the schema and check that the resolvers match it
+func newGraphQLSchema(a *application) *graphql.Schema {
+	return graphql.MustParseSchema(graphqlSchemaDefinition,
+		&graphqlResolver{app: a},
+		graphql.MaxDepth(10),
+		graphql.Logger(graphqlLogger{a.logger}))
+}
+
+// Run a GraphQL query or mutation. The body is the usual
+// {"query": ..., "operationName": ..., "variables": ...}. As GraphQL
+// wants it the status is 200 even when the response has errors
+func (a *application) graphqlHandler(w http.ResponseWriter, r *http.Request) {
+	var incomingData struct {
+		Query         string         `json:"query"`
+		OperationName string         `json:"operationName"`
+		Variables     map[string]any `json:"variables"`
+		Extensions    map[string]any `json:"extensions"` // not used
+	}
+	err := a.readJSON(w, r, &incomingData)
+	if err != nil {
+		a.badRequestResponse(w, r, err)
+		return
+	}
+
+	v := validator.New()
+	v.Check(incomingData.Query != "", "query", "must be provided")
+	v.Check(len(incomingData.Query) <= graphqlMaxQueryLength, "query",
+		fmt.Sprintf("must not be more than %d bytes long", graphqlMaxQueryLength))
+	if !v.IsEmpty() {
+		a.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	// the resolvers check the permissions for each field, we look them
+	// up once for the whole request
+	user := a.contextGetUser(r)
+	permissions, err := a.permissionModel.GetAllForUser(user.ID)
+	if err != nil {
+		a.serverErrorResponse(w, r, err)
+		return
+	}
+	session := &graphqlSession{request: r, user: user, permissions: permissions}
+	ctx := context.WithValue(r.Context(), graphqlSessionContextKey, session)
+
+	response := a.graphqlSchema.Exec(ctx, incomingData.Query,
+		incomingData.OperationName, incomingData.Variables)
+
+	data := envelope{}
+	if len(response.Errors) > 0 {
+		data["errors"] = response.Errors
+	}
+	if response.Data != nil {
+		data["data"] = response.Data
+	}
+	err = a.writeJSON(w, http.StatusOK, data, nil)
+	if err != nil {
+		a.serverErrorResponse(w, r, err)
+	}
+}
+
+const graphqlSessionContextKey = contextKey("graphql_session")
+
+// Who is making the GraphQL request and what they may do
+type graphqlSession struct {
+	request     *http.Request
+	user        *data.User
+	permissions data.Permissions
+	rootFields  atomic.Int32 // the top-level fields resolved so far
+}
+
+// Count a top-level field against graphqlMaxRootFields. The resolvers
+// call it before they do anything else
+func (s *graphqlSession) resolveRootField() error {
+	if s.rootFields.Add(1) > graphqlMaxRootFields {
+		return &graphqlError{
+			message:    fmt.Sprintf("the query must not have more than %d top-level fields", graphqlMaxRootFields),
+			extensions: map[string]any{"code": "QUERY_TOO_LARGE"},
+		}
+	}
+	return nil
+}
+
+// The user as the editor of a quote
+func (s *graphqlSession) quoteEditor() data.QuoteEditor {
+	return data.QuoteEditor{ID: s.user.ID, Moderator: s.permissions.Include("quotes:moderate")}
+}
+
+func graphqlSessionFrom(ctx context.Context) *graphqlSession {
+	session, ok := ctx.Value(graphqlSessionContextKey).(*graphqlSession)
+	if !ok {
+		panic("missing graphql session in context")
+	}
+	return session
+}
+
+// An error in the "errors" of the response. The code in the extensions
+// tells the client what went wrong, like the status of a REST response
+type graphqlError struct {
+	message    string
+	extensions map[string]any
+}
+
+func (e *graphqlError) Error() string {
+	return e.message
+}
+
+func (e *graphqlError) Extensions() map[string]any {
+	return e.extensions
+}
+
+func graphqlNotFound() error {
+	return &graphqlError{
+		message:    "the requested resource could not be found",
+		extensions: map[string]any{"code": "NOT_FOUND"},
+	}
+}
+
+func graphqlNotPermitted() error {
+	return &graphqlError{
+		message:    "your user account doesn't have the necessary permissions to access this resource",
+		extensions: map[string]any{"code": "FORBIDDEN"},
+	}
+}
+
+func graphqlFailedValidation(errors map[string]string) error {
+	return &graphqlError{
+		message:    "the input is not valid",
+		extensions: map[string]any{"code": "FAILED_VALIDATION", "errors": errors},
+	}
+}
+
+func graphqlEditConflict() error {
+	return &graphqlError{
+		message:    "unable to update the record due to an edit conflict, please try again",
+		extensions: map[string]any{"code": "EDIT_CONFLICT"},
+	}
+}
+
+func graphqlDuplicateQuote(existingID int64) error {
+	return &graphqlError{
+		message: "this quote already exists",
+		extensions: map[string]any{
+			"code":              "DUPLICATE_QUOTE",
+			"existing_quote_id": strconv.FormatInt(existingID, 10),
+		},
+	}
+}
+
+// Log the error and give the client the same message as a 500
+func (a *application) graphqlServerError(r *http.Request, err error) error {
+	a.logError(r, err)
+	return &graphqlError{
+		message:    "the server encountered a problem and could not process your request",
+		extensions: map[string]any{"code": "INTERNAL_SERVER_ERROR"},
+	}
+}
+
+// Panics in the resolvers go to our logger
+type graphqlLogger struct {
+	logger *slog.Logger
+}
+
+func (l graphqlLogger) LogPanic(ctx context.Context, value any) {
+	if l.logger != nil {
+		l.logger.Error(fmt.Sprintf("graphql: %v", value))
+	}
+}
+
+// An id from the client. Ids that are not numbers don't match anything
+func parseGraphQLID(id graphql.ID) (int64, bool) {
+	n, err := strconv.ParseInt(string(id), 10, 64)
+	if err != nil || n < 1 {
+		return 0, false
+	}
+	return n, true
+}
+
+func formatGraphQLID(id int64) graphql.ID {
+	return graphql.ID(strconv.FormatInt(id, 10))
+}
+
+func optionalGraphQLID(id *int64) *graphql.ID {
+	if id == nil {
+		return nil
+	}
+	formatted := formatGraphQLID(*id)
+	return &formatted
+}
+
+// The root of the queries and the mutations
+type graphqlResolver struct {
+	app *application
+}
+
+func (res *graphqlResolver) Quote(ctx context.Context, args struct{ ID graphql.ID }) (*quoteResolver, error) {
+	session := graphqlSessionFrom(ctx)
+	err := session.resolveRootField()
+	if err != nil {
+		return nil, err
+	}
+	if !session.permissions.Include("quotes:read") {
+		return nil, graphqlNotPermitted()
+	}
+	id, ok := parseGraphQLID(args.ID)
+	if !ok {
+		return nil, nil
+	}
+
+	quote, err := res.app.quoteModel.Get(id)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			return nil, nil
+		default:
+			return nil, res.app.graphqlServerError(session.request, err)
+		}
+	}
+	return &quoteResolver{app: res.app, quote: quote}, nil
+}
+
+// The arguments of Query.quotes. They are the query parameters of
+// GET /v1/quotes under other names
+type quotesArgs struct {
+	Content       *string
+	Author        *string
+	AuthorID      *graphql.ID
+	Tags          *[]string
+	TagsMode      *string
+	CreatedAfter  *string
+	CreatedBefore *string
+	AuthorExact   *string
+	MinLength     *int32
+	MaxLength     *int32
+	Fuzzy         *bool
+	Page          *int32
+	PageSize      *int32
+	Sort          *string
+	Cursor        *string
+}
+
+// The arguments as the query parameters of GET /v1/quotes, so that the
+// listing is read and validated the same way
+func (args quotesArgs) queryParameters() url.Values {
+	values := url.Values{}
+	setString := func(key string, value *string) {
+		if value != nil {
+			values.Set(key, *value)
+		}
+	}
+	setInt := func(key string, value *int32) {
+		if value != nil {
+			values.Set(key, strconv.Itoa(int(*value)))
+		}
+	}
+
+	setString("content", args.Content)
+	setString("author", args.Author)
+	if args.AuthorID != nil {
+		values.Set("author_id", string(*args.AuthorID))
+	}
+	if args.Tags != nil {
+		values.Set("tags", strings.Join(*args.Tags, ","))
+	}
+	setString("tags_mode", args.TagsMode)
+	setString("created_after", args.CreatedAfter)
+	setString("created_before", args.CreatedBefore)
+	setString("author_exact", args.AuthorExact)
+	setInt("min_length", args.MinLength)
+	setInt("max_length", args.MaxLength)
+	if args.Fuzzy != nil {
+		values.Set("fuzzy", strconv.FormatBool(*args.Fuzzy))
+	}
+	setInt("page", args.Page)
+	setInt("page_size", args.PageSize)
+	setString("sort", args.Sort)
+	setString("cursor", args.Cursor)
+
+	return values
+}
+
+func (res *graphqlResolver) Quotes(ctx context.Context, args quotesArgs) (*quoteListResolver, error) {
+	session := graphqlSessionFrom(ctx)
+	err := session.resolveRootField()
+	if err != nil {
+		return nil, err
+	}
+	if !session.permissions.Include("quotes:read") {
+		return nil, graphqlNotPermitted()
+	}
+
+	queryParameters := args.queryParameters()
+	validationErrors := res.app.checkQueryParameters("/v1/quotes", queryParameters)
+	if len(validationErrors) > 0 {
+		return nil, graphqlFailedValidation(validationErrors)
+	}
+	v := validator.New()
+	quoteFilters, filters := res.app.getQuoteListing(queryParameters, v)
+	if !v.IsEmpty() {
+		return nil, graphqlFailedValidation(v.Errors)
+	}
+
+	quotes, metadata, err := res.app.quoteModel.GetAll(quoteFilters, filters)
+	if err != nil {
+		return nil, res.app.graphqlServerError(session.request, err)
+	}
+	return &quoteListResolver{app: res.app, quotes: quotes, metadata: metadata}, nil
+}
+
+func (res *graphqlResolver) Author(ctx context.Context, args struct{ ID graphql.ID }) (*authorResolver, error) {
+	session := graphqlSessionFrom(ctx)
+	err := session.resolveRootField()
+	if err != nil {
+		return nil, err
+	}
+	if !session.permissions.Include("quotes:read") {
+		return nil, graphqlNotPermitted()
+	}
+	id, ok := parseGraphQLID(args.ID)
+	if !ok {
+		return nil, nil
+	}
+
+	author, err := res.app.authorModel.Get(id)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			return nil, nil
+		default:
+			return nil, res.app.graphqlServerError(session.request, err)
+		}
+	}
+	return &authorResolver{author: author}, nil
+}
+
+func (res *graphqlResolver) Me(ctx context.Context) *userResolver {
+	session := graphqlSessionFrom(ctx)
+	return &userResolver{user: session.user, permissions: session.permissions}
+}
+
+type quoteInput struct {
+	Content  string
+	Author   *string
+	AuthorID *graphql.ID
+	Tags     *[]string
+}
+
+// The same steps as createQuoteHandler
+func (res *graphqlResolver) CreateQuote(ctx context.Context, args struct {
+	Input          quoteInput
+	AllowDuplicate *bool
+}) (*quoteResolver, error) {
+	session := graphqlSessionFrom(ctx)
+	err := session.resolveRootField()
+	if err != nil {
+		return nil, err
+	}
+	if !session.permissions.Include("quotes:write") {
+		return nil, graphqlNotPermitted()
+	}
+
+	quote := &data.Quote{
+		Content:   args.Input.Content,
+		CreatedBy: &session.user.ID,
+	}
+	if args.Input.Author != nil {
+		quote.Author = *args.Input.Author
+	}
+	if args.Input.Tags != nil {
+		quote.Tags = data.NormalizeTags(*args.Input.Tags)
+	}
+	if args.Input.AuthorID != nil {
+		err := res.setQuoteAuthor(session, quote, *args.Input.AuthorID)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	v := validator.New()
+	data.ValidateQuote(v, quote)
+	if !v.IsEmpty() {
+		return nil, graphqlFailedValidation(v.Errors)
+	}
+
+	if args.AllowDuplicate != nil && *args.AllowDuplicate {
+		if !session.permissions.Include("quotes:moderate") {
+			return nil, graphqlNotPermitted()
+		}
+		err = res.app.quoteModel.Insert(quote)
+	} else {
+		var existing *data.Quote
+		existing, err = res.app.quoteModel.InsertUnique(quote)
+		if errors.Is(err, data.ErrDuplicateQuote) {
+			return nil, graphqlDuplicateQuote(existing.ID)
+		}
+	}
+	if err != nil {
+		return nil, res.app.graphqlServerError(session.request, err)
+	}
+	res.app.queueWebhooks(session.request, data.WebhookPayload{
+		Event:   data.QuoteCreated,
+		QuoteID: quote.ID,
+		Quote:   quote,
+	})
+
+	return &quoteResolver{app: res.app, quote: quote}, nil
+}
+
+type quoteUpdate struct {
+	Content  *string
+	Author   *string
+	AuthorID *graphql.ID
+	Tags     *[]string
+}
+
+// The same steps as updateQuoteHandler. The version takes the place of
+// the If-Match header
+func (res *graphqlResolver) UpdateQuote(ctx context.Context, args struct {
+	ID      graphql.ID
+	Input   quoteUpdate
+	Version *int32
+}) (*quoteResolver, error) {
+	session := graphqlSessionFrom(ctx)
+	err := session.resolveRootField()
+	if err != nil {
+		return nil, err
+	}
+	quote, err := res.quoteToModify(session, args.ID)
+	if err != nil {
+		return nil, err
+	}
+	if args.Version != nil && *args.Version != quote.Version {
+		return nil, graphqlEditConflict()
+	}
+
+	if args.Input.Content != nil {
+		quote.Content = *args.Input.Content
+	}
+	if args.Input.Author != nil {
+		quote.Author = *args.Input.Author
+		quote.AuthorID = nil
+	}
+	if args.Input.AuthorID != nil {
+		err = res.setQuoteAuthor(session, quote, *args.Input.AuthorID)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if args.Input.Tags != nil {
+		quote.Tags = data.NormalizeTags(*args.Input.Tags)
+	}
+
+	v := validator.New()
+	data.ValidateQuote(v, quote)
+	if !v.IsEmpty() {
+		return nil, graphqlFailedValidation(v.Errors)
+	}
+
+	err = res.app.quoteModel.Update(quote, session.quoteEditor())
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			return nil, graphqlNotFound()
+		case errors.Is(err, data.ErrNotPermitted):
+			return nil, graphqlNotPermitted()
+		case errors.Is(err, data.ErrEditConflict):
+			return nil, graphqlEditConflict()
+		default:
+			return nil, res.app.graphqlServerError(session.request, err)
+		}
+	}
+	res.app.queueWebhooks(session.request, data.WebhookPayload{
+		Event:   data.QuoteUpdated,
+		QuoteID: quote.ID,
+		Quote:   quote,
+	})
+
+	return &quoteResolver{app: res.app, quote: quote}, nil
+}
+
+// The same steps as deleteQuoteHandler, the quote goes to the trash
+func (res *graphqlResolver) DeleteQuote(ctx context.Context, args struct{ ID graphql.ID }) (graphql.ID, error) {
+	session := graphqlSessionFrom(ctx)
+	err := session.resolveRootField()
+	if err != nil {
+		return "", err
+	}
+	quote, err := res.quoteToModify(session, args.ID)
+	if err != nil {
+		return "", err
+	}
+
+	// only if nobody changed it since we read it
+	err = res.app.quoteModel.Delete(quote.ID, quote.Version, session.quoteEditor())
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			return "", graphqlNotFound()
+		case errors.Is(err, data.ErrNotPermitted):
+			return "", graphqlNotPermitted()
+		case errors.Is(err, data.ErrEditConflict):
+			return "", graphqlEditConflict()
+		default:
+			return "", res.app.graphqlServerError(session.request, err)
+		}
+	}
+	res.app.queueWebhooks(session.request, data.WebhookPayload{
+		Event:   data.QuoteDeleted,
+		QuoteID: quote.ID,
+	})
+
+	return formatGraphQLID(quote.ID), nil
+}
+
+// Get a quote that the user is about to change. Like the REST routes
+// that means quotes:write. Whether the quote is theirs to change is up
+// to the model (see data.QuoteEditor)
+func (res *graphqlResolver) quoteToModify(session *graphqlSession, id graphql.ID) (*data.Quote, error) {
+	if !session.permissions.Include("quotes:write") {
+		return nil, graphqlNotPermitted()
+	}
+	quoteID, ok := parseGraphQLID(id)
+	if !ok {
+		return nil, graphqlNotFound()
+	}
+
+	quote, err := res.app.quoteModel.Get(quoteID)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			return nil, graphqlNotFound()
+		default:
+			return nil, res.app.graphqlServerError(session.request, err)
+		}
+	}
+
+	return quote, nil
+}
+
+// Like setQuoteAuthor, for the resolvers
+func (res *graphqlResolver) setQuoteAuthor(session *graphqlSession, quote *data.Quote, id graphql.ID) error {
+	v := validator.New()
+
+	authorID, ok := parseGraphQLID(id)
+	if !ok {
+		v.AddError("author_id", "must refer to an existing author")
+		return graphqlFailedValidation(v.Errors)
+	}
+	author, err := res.app.authorModel.Get(authorID)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			v.AddError("author_id", "must refer to an existing author")
+			return graphqlFailedValidation(v.Errors)
+		default:
+			return res.app.graphqlServerError(session.request, err)
+		}
+	}
+	quote.Author = author.Name
+	quote.AuthorID = &author.ID
+
+	return nil
+}
+
+type quoteResolver struct {
+	app   *application
+	quote *data.Quote
+	// the authors of the quotes of a list, nil for a single quote
+	authors *authorBatch
+}
+
+func (q *quoteResolver) ID() graphql.ID        { return formatGraphQLID(q.quote.ID) }
+func (q *quoteResolver) Content() string       { return q.quote.Content }
+func (q *quoteResolver) Author() string        { return q.quote.Author }
+func (q *quoteResolver) AuthorID() *graphql.ID { return optionalGraphQLID(q.quote.AuthorID) }
+func (q *quoteResolver) CreatedBy() *graphql.ID {
+	return optionalGraphQLID(q.quote.CreatedBy)
+}
+func (q *quoteResolver) CreatedAt() graphql.Time { return graphql.Time{Time: q.quote.CreatedAt} }
+func (q *quoteResolver) UpdatedAt() graphql.Time { return graphql.Time{Time: q.quote.UpdatedAt} }
+func (q *quoteResolver) Version() int32          { return q.quote.Version }
+
+func (q *quoteResolver) Tags() []string {
+	if q.quote.Tags == nil {
+		return []string{}
+	}
+	return q.quote.Tags
+}
+
+func (q *quoteResolver) AuthorProfile(ctx context.Context) (*authorResolver, error) {
+	if q.quote.AuthorID == nil {
+		return nil, nil
+	}
+	if q.authors == nil {
+		q.authors = newAuthorBatch(q.app, []*data.Quote{q.quote})
+	}
+	author, err := q.authors.get(*q.quote.AuthorID)
+	if err != nil {
+		return nil, q.app.graphqlServerError(graphqlSessionFrom(ctx).request, err)
+	}
+	if author == nil {
+		return nil, nil
+	}
+	return &authorResolver{author: author}, nil
+}
+
+// The authors of some quotes. They are looked up all at once the first
+// time one of them is asked for, rather than one query per quote
+type authorBatch struct {
+	app     *application
+	ids     []int64
+	once    sync.Once
+	authors map[int64]*data.Author
+	err     error
+}
+
+func newAuthorBatch(app *application, quotes []*data.Quote) *authorBatch {
+	batch := &authorBatch{app: app}
+	for _, quote := range quotes {
+		if quote.AuthorID != nil {
+			batch.ids = append(batch.ids, *quote.AuthorID)
+		}
+	}
+	return batch
+}
+
+// The author with the id, nil if there is none
+func (b *authorBatch) get(id int64) (*data.Author, error) {
+	b.once.Do(func() {
+		b.authors, b.err = b.app.authorModel.GetMany(b.ids)
+	})
+	return b.authors[id], b.err
+}
+
+type quoteListResolver struct {
+	app      *application
+	quotes   []*data.Quote
+	metadata data.Metadata
+}
+
+func (l *quoteListResolver) Quotes() []*quoteResolver {
+	authors := newAuthorBatch(l.app, l.quotes)
+	quotes := make([]*quoteResolver, 0, len(l.quotes))
+	for _, quote := range l.quotes {
+		quotes = append(quotes, &quoteResolver{app: l.app, quote: quote, authors: authors})
+	}
+	return quotes
+}
+
+func (l *quoteListResolver) Metadata() *metadataResolver {
+	return &metadataResolver{metadata: l.metadata}
+}
+
+// The fields are null where the JSON metadata leaves them out
+type metadataResolver struct {
+	metadata data.Metadata
+}
+
+func optionalInt(n int) *int32 {
+	if n == 0 {
+		return nil
+	}
+	i := int32(n)
+	return &i
+}
+
+func (m *metadataResolver) CurrentPage() *int32  { return optionalInt(m.metadata.CurrentPage) }
+func (m *metadataResolver) PageSize() *int32     { return optionalInt(m.metadata.PageSize) }
+func (m *metadataResolver) FirstPage() *int32    { return optionalInt(m.metadata.FirstPage) }
+func (m *metadataResolver) LastPage() *int32     { return optionalInt(m.metadata.LastPage) }
+func (m *metadataResolver) TotalRecords() *int32 { return optionalInt(m.metadata.TotalRecords) }
+
+func (m *metadataResolver) NextCursor() *string {
+	if m.metadata.NextCursor == "" {
+		return nil
+	}
+	return &m.metadata.NextCursor
+}
+
+type authorResolver struct {
+	author *data.Author
+}
+
+func (a *authorResolver) ID() graphql.ID    { return formatGraphQLID(a.author.ID) }
+func (a *authorResolver) Name() string      { return a.author.Name }
+func (a *authorResolver) Slug() string      { return a.author.Slug }
+func (a *authorResolver) Bio() string       { return a.author.Bio }
+func (a *authorResolver) BirthYear() *int32 { return a.author.BirthYear }
+func (a *authorResolver) DeathYear() *int32 { return a.author.DeathYear }
+func (a *authorResolver) Version() int32    { return a.author.Version }
+func (a *authorResolver) Aliases() []string {
+	if a.author.Aliases == nil {
+		return []string{}
+	}
+	return a.author.Aliases
+}
+
+type userResolver struct {
+	user        *data.User
+	permissions data.Permissions
+}
+
+func (u *userResolver) ID() graphql.ID          { return formatGraphQLID(u.user.ID) }
+func (u *userResolver) Username() string        { return u.user.Username }
+func (u *userResolver) Email() string           { return u.user.Email }
+func (u *userResolver) Activated() bool         { return u.user.Activated }
+func (u *userResolver) CreatedAt() graphql.Time { return graphql.Time{Time: u.user.CreatedAt} }
+
+func (u *userResolver) Permissions() []string {
+	if u.permissions == nil {
+		return []string{}
+	}
+	return u.permissions
+}
@@ -0,0 +1,75 @@
+// Filename: cmd/api/graphql_internal_test.go
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/2016114132/qod/internal/data"
+)
+
+func TestGraphQL(t *testing.T) {
+	// MustParseSchema panics if the resolvers don't match the schema
+	app := &application{openAPIRouter: newOpenAPIRouter()}
+	schema := newGraphQLSchema(app)
+
+	// the same field 21 times under different aliases
+	manyQuotes := "{"
+	for i := range 21 {
+		manyQuotes += fmt.Sprintf(` q%d: quote(id: "abc") { id }`, i)
+	}
+	manyQuotes += " }"
+
+	tests := []struct {
+		name  string
+		query string
+		data  string // "" when we expect an error
+		code  string
+	}{
+		{"me", `{ me { id username permissions } }`,
+			`{"me":{"id":"7","username":"ana","permissions":["quotes:read"]}}`, ""},
+		{"not a quote id", `{ quote(id: "abc") { id } }`, `{"quote":null}`, ""},
+		{"no quotes:write", `mutation { deleteQuote(id: "1") }`, "", "FORBIDDEN"},
+		{"invalid sort", `{ quotes(sort: "nope") { quotes { id } } }`, "", "FAILED_VALIDATION"},
+		{"too many fields", manyQuotes, "", "QUERY_TOO_LARGE"},
+	}
+
+	for _, tt := range tests {
+		session := &graphqlSession{
+			request:     httptest.NewRequest("POST", "/v1/graphql", nil),
+			user:        &data.User{ID: 7, Username: "ana", Activated: true},
+			permissions: data.Permissions{"quotes:read"},
+		}
+		ctx := context.WithValue(context.Background(), graphqlSessionContextKey, session)
+		response := schema.Exec(ctx, tt.query, "", nil)
+
+		if tt.data != "" {
+			if len(response.Errors) > 0 {
+				t.Errorf("%s: expected: no errors, got: %v", tt.name, response.Errors)
+				continue
+			}
+			var got, want any
+			_ = json.Unmarshal(response.Data, &got)
+			_ = json.Unmarshal([]byte(tt.data), &want)
+			gotJSON, _ := json.Marshal(got)
+			wantJSON, _ := json.Marshal(want)
+			if string(gotJSON) != string(wantJSON) {
+				t.Errorf("%s: expected: %s, got: %s", tt.name, wantJSON, gotJSON)
+			}
+			continue
+		}
+
+		if len(response.Errors) != 1 {
+			t.Errorf("%s: expected: one error, got: %v", tt.name, response.Errors)
+			continue
+		}
+		code := response.Errors[0].Extensions["code"]
+		if code != tt.code {
+			t.Errorf("%s: expected: %q, got: %q", tt.name, tt.code, code)
+		}
+	}
+}
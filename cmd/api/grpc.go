@@ -0,0 +1,657 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"expvar"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/2016114132/qod/internal/data"
+	"github.com/2016114132/qod/internal/quotepb"
+	"github.com/2016114132/qod/internal/validator"
+	"golang.org/x/time/rate"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/protoadapt"
+	"google.golang.org/protobuf/types/known/emptypb"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// The permission that each method needs, the same as its route. The
+// methods with no permission are public
+var grpcPermissions = map[string]string{
+	quotepb.QuoteService_Get_FullMethodName:    "quotes:read",
+	quotepb.QuoteService_List_FullMethodName:   "quotes:read",
+	quotepb.QuoteService_Create_FullMethodName: "quotes:write",
+	quotepb.QuoteService_Update_FullMethodName: "quotes:write",
+	quotepb.QuoteService_Delete_FullMethodName: "quotes:write",
+	quotepb.QuoteService_Today_FullMethodName:  "",
+}
+
+func (a *application) newGRPCServer() *grpc.Server {
+	// the same steps as the middleware of our routes
+	srv := grpc.NewServer(grpc.ChainUnaryInterceptor(a.grpcMetrics, a.grpcRecoverPanic,
+		a.grpcRateLimit(), a.grpcAuthenticate))
+	quotepb.RegisterQuoteServiceServer(srv, &quoteService{app: a})
+	return srv
+}
+
+// Listen on the gRPC port and serve in the background until the server
+// is stopped. We listen first so that a port in use stops us at startup
+func (a *application) serveGRPC() (*grpc.Server, error) {
+	listener, err := net.Listen("tcp", fmt.Sprintf(":%d", a.config.grpc.port))
+	if err != nil {
+		return nil, err
+	}
+	srv := a.newGRPCServer()
+
+	a.logger.Info("starting grpc server", "addr", listener.Addr().String())
+	go func() {
+		err := srv.Serve(listener)
+		if err != nil {
+			a.logger.Error(err.Error())
+		}
+	}()
+
+	return srv, nil
+}
+
+// Stop the gRPC server the way http.Server.Shutdown() stops ours: the
+// calls in progress may finish, but not for longer than ctx allows
+func stopGRPCServer(ctx context.Context, srv *grpc.Server) {
+	stopped := make(chan struct{})
+	go func() {
+		srv.GracefulStop()
+		close(stopped)
+	}()
+
+	select {
+	case <-stopped:
+	case <-ctx.Done():
+		srv.Stop()
+		<-stopped
+	}
+}
+
+const grpcSessionContextKey = contextKey("grpc_session")
+
+// Who is making the call and what they may do. A gRPC call is a POST to
+// the method, we keep one as the request for logError and the webhooks
+type grpcSession struct {
+	request     *http.Request
+	user        *data.User
+	permissions data.Permissions
+}
+
+// The user as the editor of a quote
+func (s *grpcSession) quoteEditor() data.QuoteEditor {
+	return data.QuoteEditor{ID: s.user.ID, Moderator: s.permissions.Include("quotes:moderate")}
+}
+
+func grpcSessionFrom(ctx context.Context) *grpcSession {
+	session, ok := ctx.Value(grpcSessionContextKey).(*grpcSession)
+	if !ok {
+		panic("missing grpc session in context")
+	}
+	return session
+}
+
+// Like recoverPanic, a panic in a method becomes an internal error
+func (a *application) grpcRecoverPanic(ctx context.Context, req any,
+	info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (resp any, err error) {
+	defer func() {
+		p := recover()
+		if p != nil {
+			a.logger.Error(fmt.Sprintf("%s", p), "method", info.FullMethod)
+			err = grpcInternal()
+		}
+	}()
+	return handler(ctx, req)
+}
+
+// The metrics of the gRPC calls, kept next to the ones of the HTTP
+// requests. expvar names can only be used once, so they are set up here
+// rather than in newGRPCServer()
+var (
+	grpcTotalRequestsReceived           = expvar.NewInt("grpc_total_requests_received")
+	grpcTotalResponsesSent              = expvar.NewInt("grpc_total_responses_sent")
+	grpcTotalProcessingTimeMicroseconds = expvar.NewInt("grpc_total_processing_time_μs")
+	grpcTotalResponsesSentByCode        = expvar.NewMap("grpc_total_responses_sent_by_code")
+)
+
+// The metrics middleware for the gRPC calls. The responses are counted
+// by their gRPC status code (OK, NotFound, ...)
+func (a *application) grpcMetrics(ctx context.Context, req any,
+	info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+	start := time.Now()
+	grpcTotalRequestsReceived.Add(1)
+
+	resp, err := handler(ctx, req)
+
+	grpcTotalResponsesSent.Add(1)
+	grpcTotalResponsesSentByCode.Add(status.Code(err).String(), 1)
+	grpcTotalProcessingTimeMicroseconds.Add(time.Since(start).Microseconds())
+
+	return resp, err
+}
+
+// The rateLimit middleware for the gRPC calls, with the same limits for
+// each client IP address
+func (a *application) grpcRateLimit() grpc.UnaryServerInterceptor {
+	type client struct {
+		limiter  *rate.Limiter
+		lastSeen time.Time
+	}
+	var mu sync.Mutex
+	clients := make(map[string]*client)
+	// remove the clients not seen in three minutes
+	go func() {
+		for {
+			time.Sleep(time.Minute)
+			mu.Lock()
+			for ip, client := range clients {
+				if time.Since(client.lastSeen) > 3*time.Minute {
+					delete(clients, ip)
+				}
+			}
+			mu.Unlock()
+		}
+	}()
+
+	return func(ctx context.Context, req any,
+		info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		if !a.config.limiter.enabled {
+			return handler(ctx, req)
+		}
+		p, ok := peer.FromContext(ctx)
+		if !ok {
+			return nil, grpcInternal()
+		}
+		ip, _, err := net.SplitHostPort(p.Addr.String())
+		if err != nil {
+			return nil, grpcInternal()
+		}
+
+		mu.Lock()
+		_, found := clients[ip]
+		if !found {
+			clients[ip] = &client{limiter: rate.NewLimiter(
+				rate.Limit(a.config.limiter.rps),
+				a.config.limiter.burst)}
+		}
+		clients[ip].lastSeen = time.Now()
+		allowed := clients[ip].limiter.Allow()
+		mu.Unlock()
+
+		if !allowed {
+			return nil, status.Error(codes.ResourceExhausted, "rate limit exceeded")
+		}
+		return handler(ctx, req)
+	}
+}
+
+// The authenticate, requireActivatedUser and requirePermission
+// middleware in one. The token comes in the authorization metadata
+func (a *application) grpcAuthenticate(ctx context.Context, req any,
+	info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+	r, err := http.NewRequestWithContext(ctx, http.MethodPost, info.FullMethod, nil)
+	if err != nil {
+		return nil, grpcInternal()
+	}
+
+	user, err := a.grpcUser(ctx, r)
+	if err != nil {
+		return nil, err
+	}
+	session := &grpcSession{request: r, user: user}
+
+	permission, ok := grpcPermissions[info.FullMethod]
+	if !ok {
+		return nil, grpcNotPermitted()
+	}
+	if permission != "" {
+		if user.IsAnonymous() {
+			return nil, status.Error(codes.Unauthenticated,
+				"you must be authenticated to access this resource")
+		}
+		if !user.Activated {
+			return nil, status.Error(codes.PermissionDenied,
+				"your user account must be activated to access this resource")
+		}
+		session.permissions, err = a.permissionModel.GetAllForUser(user.ID)
+		if err != nil {
+			return nil, a.grpcServerError(r, err)
+		}
+		if !session.permissions.Include(permission) {
+			return nil, grpcNotPermitted()
+		}
+	}
+
+	return handler(context.WithValue(ctx, grpcSessionContextKey, session), req)
+}
+
+// The user with the bearer token of the call, the anonymous user when
+// there is none
+func (a *application) grpcUser(ctx context.Context, r *http.Request) (*data.User, error) {
+	invalidToken := status.Error(codes.Unauthenticated, "invalid or missing authentication token")
+
+	md, _ := metadata.FromIncomingContext(ctx)
+	values := md.Get("authorization")
+	if len(values) == 0 {
+		return data.AnonymousUser, nil
+	}
+	headerParts := strings.Split(values[0], " ")
+	if len(values) != 1 || len(headerParts) != 2 || headerParts[0] != "Bearer" {
+		return nil, invalidToken
+	}
+
+	token := headerParts[1]
+	v := validator.New()
+	data.ValidateTokenPlaintext(v, token)
+	if !v.IsEmpty() {
+		return nil, invalidToken
+	}
+
+	user, err := a.userModel.GetForToken(data.ScopeAuthentication, token)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			return nil, invalidToken
+		default:
+			return nil, a.grpcServerError(r, err)
+		}
+	}
+	return user, nil
+}
+
+// The errors of the methods, with the messages of the REST responses
+
+func grpcNotFound() error {
+	return status.Error(codes.NotFound, "the requested resource could not be found")
+}
+
+func grpcNotPermitted() error {
+	return status.Error(codes.PermissionDenied,
+		"your user account doesn't have the necessary permissions to access this resource")
+}
+
+func grpcEditConflict() error {
+	return status.Error(codes.Aborted,
+		"unable to update the record due to an edit conflict, please try again")
+}
+
+func grpcInternal() error {
+	return status.Error(codes.Internal,
+		"the server encountered a problem and could not process your request")
+}
+
+// The errors of the validator go in the details, one violation per field
+func grpcFailedValidation(errors map[string]string) error {
+	fields := make([]string, 0, len(errors))
+	for field := range errors {
+		fields = append(fields, field)
+	}
+	sort.Strings(fields)
+
+	details := &errdetails.BadRequest{}
+	for _, field := range fields {
+		details.FieldViolations = append(details.FieldViolations,
+			&errdetails.BadRequest_FieldViolation{Field: field, Description: errors[field]})
+	}
+	return grpcWithDetails(codes.InvalidArgument, "the input is not valid", details)
+}
+
+func grpcDuplicateQuote(existingID int64) error {
+	details := &errdetails.ErrorInfo{
+		Reason:   "DUPLICATE_QUOTE",
+		Domain:   "qod",
+		Metadata: map[string]string{"existing_quote_id": strconv.FormatInt(existingID, 10)},
+	}
+	return grpcWithDetails(codes.AlreadyExists, "this quote already exists", details)
+}
+
+func grpcWithDetails(code codes.Code, message string, details protoadapt.MessageV1) error {
+	st, err := status.New(code, message).WithDetails(details)
+	if err != nil {
+		return status.Error(code, message)
+	}
+	return st.Err()
+}
+
+// Log the error and give the client the same message as a 500
+func (a *application) grpcServerError(r *http.Request, err error) error {
+	a.logError(r, err)
+	return grpcInternal()
+}
+
+// The QuoteService of internal/quotepb/quotes.proto. The methods take
+// the same steps as the quote handlers
+type quoteService struct {
+	quotepb.UnimplementedQuoteServiceServer
+	app *application
+}
+
+func (s *quoteService) Get(ctx context.Context, req *quotepb.GetQuoteRequest) (*quotepb.Quote, error) {
+	session := grpcSessionFrom(ctx)
+	if req.Id < 1 {
+		return nil, grpcNotFound()
+	}
+
+	quote, err := s.app.quoteModel.Get(req.Id)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			return nil, grpcNotFound()
+		default:
+			return nil, s.app.grpcServerError(session.request, err)
+		}
+	}
+	return quoteToProto(quote), nil
+}
+
+// The fields of the request as the query parameters of GET /v1/quotes,
+// so that they are read and checked the same way
+func listQuotesParameters(req *quotepb.ListQuotesRequest) url.Values {
+	queryParameters := url.Values{}
+	setString := func(key, value string) {
+		if value != "" {
+			queryParameters.Set(key, value)
+		}
+	}
+	setInt := func(key string, value int64) {
+		if value != 0 {
+			queryParameters.Set(key, strconv.FormatInt(value, 10))
+		}
+	}
+
+	setString("content", req.Content)
+	setString("author", req.Author)
+	setInt("author_id", req.AuthorId)
+	setString("tags", strings.Join(req.Tags, ","))
+	setString("tags_mode", req.TagsMode)
+	setString("created_after", req.CreatedAfter)
+	setString("created_before", req.CreatedBefore)
+	setString("author_exact", req.AuthorExact)
+	setInt("min_length", int64(req.MinLength))
+	setInt("max_length", int64(req.MaxLength))
+	if req.Fuzzy {
+		queryParameters.Set("fuzzy", "true")
+	}
+	setInt("page", int64(req.Page))
+	setInt("page_size", int64(req.PageSize))
+	setString("sort", req.Sort)
+	setString("cursor", req.Cursor)
+
+	return queryParameters
+}
+
+func (s *quoteService) List(ctx context.Context, req *quotepb.ListQuotesRequest) (*quotepb.ListQuotesResponse, error) {
+	session := grpcSessionFrom(ctx)
+
+	queryParameters := listQuotesParameters(req)
+	validationErrors := s.app.checkQueryParameters("/v1/quotes", queryParameters)
+	if len(validationErrors) > 0 {
+		return nil, grpcFailedValidation(validationErrors)
+	}
+	v := validator.New()
+	quoteFilters, filters := s.app.getQuoteListing(queryParameters, v)
+	if !v.IsEmpty() {
+		return nil, grpcFailedValidation(v.Errors)
+	}
+
+	quotes, metadata, err := s.app.quoteModel.GetAll(quoteFilters, filters)
+	if err != nil {
+		return nil, s.app.grpcServerError(session.request, err)
+	}
+
+	response := &quotepb.ListQuotesResponse{
+		Metadata: &quotepb.Metadata{
+			CurrentPage:  int32(metadata.CurrentPage),
+			PageSize:     int32(metadata.PageSize),
+			FirstPage:    int32(metadata.FirstPage),
+			LastPage:     int32(metadata.LastPage),
+			TotalRecords: int32(metadata.TotalRecords),
+			NextCursor:   metadata.NextCursor,
+		},
+	}
+	for _, quote := range quotes {
+		response.Quotes = append(response.Quotes, quoteToProto(quote))
+	}
+	return response, nil
+}
+
+func (s *quoteService) Create(ctx context.Context, req *quotepb.CreateQuoteRequest) (*quotepb.Quote, error) {
+	session := grpcSessionFrom(ctx)
+
+	quote := &data.Quote{
+		Content:   req.Content,
+		Author:    req.Author,
+		Tags:      data.NormalizeTags(req.Tags),
+		CreatedBy: &session.user.ID,
+	}
+	if req.AuthorId != nil {
+		err := s.setQuoteAuthor(session, quote, *req.AuthorId)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	v := validator.New()
+	data.ValidateQuote(v, quote)
+	if !v.IsEmpty() {
+		return nil, grpcFailedValidation(v.Errors)
+	}
+
+	var err error
+	if req.AllowDuplicate {
+		if !session.permissions.Include("quotes:moderate") {
+			return nil, grpcNotPermitted()
+		}
+		err = s.app.quoteModel.Insert(quote)
+	} else {
+		var existing *data.Quote
+		existing, err = s.app.quoteModel.InsertUnique(quote)
+		if errors.Is(err, data.ErrDuplicateQuote) {
+			return nil, grpcDuplicateQuote(existing.ID)
+		}
+	}
+	if err != nil {
+		return nil, s.app.grpcServerError(session.request, err)
+	}
+	s.app.queueWebhooks(session.request, data.WebhookPayload{
+		Event:   data.QuoteCreated,
+		QuoteID: quote.ID,
+		Quote:   quote,
+	})
+
+	return quoteToProto(quote), nil
+}
+
+// The version takes the place of the If-Match header
+func (s *quoteService) Update(ctx context.Context, req *quotepb.UpdateQuoteRequest) (*quotepb.Quote, error) {
+	session := grpcSessionFrom(ctx)
+	quote, err := s.quoteToModify(session, req.Id)
+	if err != nil {
+		return nil, err
+	}
+	if req.Version != nil && *req.Version != quote.Version {
+		return nil, grpcEditConflict()
+	}
+
+	if req.Content != nil {
+		quote.Content = *req.Content
+	}
+	if req.Author != nil {
+		quote.Author = *req.Author
+		quote.AuthorID = nil
+	}
+	if req.AuthorId != nil {
+		err = s.setQuoteAuthor(session, quote, *req.AuthorId)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if req.Tags != nil {
+		quote.Tags = data.NormalizeTags(req.Tags.Names)
+	}
+
+	v := validator.New()
+	data.ValidateQuote(v, quote)
+	if !v.IsEmpty() {
+		return nil, grpcFailedValidation(v.Errors)
+	}
+
+	err = s.app.quoteModel.Update(quote, session.quoteEditor())
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			return nil, grpcNotFound()
+		case errors.Is(err, data.ErrNotPermitted):
+			return nil, grpcNotPermitted()
+		case errors.Is(err, data.ErrEditConflict):
+			return nil, grpcEditConflict()
+		default:
+			return nil, s.app.grpcServerError(session.request, err)
+		}
+	}
+	s.app.queueWebhooks(session.request, data.WebhookPayload{
+		Event:   data.QuoteUpdated,
+		QuoteID: quote.ID,
+		Quote:   quote,
+	})
+
+	return quoteToProto(quote), nil
+}
+
+// The quote goes to the trash, like with DELETE /v1/quotes/:id
+func (s *quoteService) Delete(ctx context.Context, req *quotepb.DeleteQuoteRequest) (*emptypb.Empty, error) {
+	session := grpcSessionFrom(ctx)
+	quote, err := s.quoteToModify(session, req.Id)
+	if err != nil {
+		return nil, err
+	}
+
+	// only if nobody changed it since we read it
+	err = s.app.quoteModel.Delete(quote.ID, quote.Version, session.quoteEditor())
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			return nil, grpcNotFound()
+		case errors.Is(err, data.ErrNotPermitted):
+			return nil, grpcNotPermitted()
+		case errors.Is(err, data.ErrEditConflict):
+			return nil, grpcEditConflict()
+		default:
+			return nil, s.app.grpcServerError(session.request, err)
+		}
+	}
+	s.app.queueWebhooks(session.request, data.WebhookPayload{
+		Event:   data.QuoteDeleted,
+		QuoteID: quote.ID,
+	})
+
+	return &emptypb.Empty{}, nil
+}
+
+func (s *quoteService) Today(ctx context.Context, req *quotepb.TodayRequest) (*quotepb.TodayResponse, error) {
+	session := grpcSessionFrom(ctx)
+
+	queryParameters := url.Values{}
+	if req.Date != "" {
+		queryParameters.Set("date", req.Date)
+	}
+	if req.Tz != "" {
+		queryParameters.Set("tz", req.Tz)
+	}
+	validationErrors := s.app.checkQueryParameters("/v1/quotes/today", queryParameters)
+	if len(validationErrors) > 0 {
+		return nil, grpcFailedValidation(validationErrors)
+	}
+	v := validator.New()
+	day := s.app.getDayParameters(queryParameters, v)
+	if !v.IsEmpty() {
+		return nil, grpcFailedValidation(v.Errors)
+	}
+
+	quote, featured, err := s.app.quoteForDay(day)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			return nil, grpcNotFound()
+		default:
+			return nil, s.app.grpcServerError(session.request, err)
+		}
+	}
+
+	return &quotepb.TodayResponse{
+		Date:     day.Format("2006-01-02"),
+		Featured: featured,
+		Quote:    quoteToProto(quote),
+	}, nil
+}
+
+// Get a quote that the user is about to change. The interceptor already
+// checked quotes:write. Whether the quote is theirs to change is up to
+// the model (see data.QuoteEditor)
+func (s *quoteService) quoteToModify(session *grpcSession, id int64) (*data.Quote, error) {
+	if id < 1 {
+		return nil, grpcNotFound()
+	}
+
+	quote, err := s.app.quoteModel.Get(id)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			return nil, grpcNotFound()
+		default:
+			return nil, s.app.grpcServerError(session.request, err)
+		}
+	}
+
+	return quote, nil
+}
+
+// Like setQuoteAuthor, for the methods
+func (s *quoteService) setQuoteAuthor(session *grpcSession, quote *data.Quote, authorID int64) error {
+	author, err := s.app.authorModel.Get(authorID)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			v := validator.New()
+			v.AddError("author_id", "must refer to an existing author")
+			return grpcFailedValidation(v.Errors)
+		default:
+			return s.app.grpcServerError(session.request, err)
+		}
+	}
+	quote.Author = author.Name
+	quote.AuthorID = &author.ID
+
+	return nil
+}
+
+func quoteToProto(quote *data.Quote) *quotepb.Quote {
+	return &quotepb.Quote{
+		Id:        quote.ID,
+		Content:   quote.Content,
+		Author:    quote.Author,
+		AuthorId:  quote.AuthorID,
+		CreatedBy: quote.CreatedBy,
+		Tags:      quote.Tags,
+		CreatedAt: timestamppb.New(quote.CreatedAt),
+		UpdatedAt: timestamppb.New(quote.UpdatedAt),
+		Version:   quote.Version,
+	}
+}
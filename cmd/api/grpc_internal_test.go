@@ -0,0 +1,109 @@
+// Filename: cmd/api/grpc_internal_test.go
+
+package main
+
+import (
+	"context"
+	"net"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/2016114132/qod/internal/data"
+	"github.com/2016114132/qod/internal/quotepb"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+func TestGRPCAuthentication(t *testing.T) {
+	// none of these calls get as far as the database
+	app := &application{}
+	listener := bufconn.Listen(1024 * 1024)
+	srv := app.newGRPCServer()
+	go srv.Serve(listener)
+	defer stopGRPCServer(context.Background(), srv)
+
+	conn, err := grpc.NewClient("passthrough:///bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+			return listener.DialContext(ctx)
+		}),
+		grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	client := quotepb.NewQuoteServiceClient(conn)
+
+	tests := []struct {
+		name          string
+		authorization string
+		want          codes.Code
+	}{
+		{"no token", "", codes.Unauthenticated},
+		{"not a bearer token", "Basic YW5hOnNlY3JldA==", codes.Unauthenticated},
+		{"malformed token", "Bearer abc", codes.Unauthenticated},
+	}
+
+	for _, tt := range tests {
+		ctx := context.Background()
+		if tt.authorization != "" {
+			ctx = metadata.AppendToOutgoingContext(ctx, "authorization", tt.authorization)
+		}
+		_, err := client.Get(ctx, &quotepb.GetQuoteRequest{Id: 1})
+		if got := status.Code(err); got != tt.want {
+			t.Errorf("%s: expected: %s, got: %s", tt.name, tt.want, got)
+		}
+	}
+}
+
+func TestGRPCFailedValidation(t *testing.T) {
+	service := &quoteService{app: &application{openAPIRouter: newOpenAPIRouter()}}
+	session := &grpcSession{
+		request:     httptest.NewRequest("POST", quotepb.QuoteService_List_FullMethodName, nil),
+		user:        &data.User{ID: 7, Activated: true},
+		permissions: data.Permissions{"quotes:read"},
+	}
+	ctx := context.WithValue(context.Background(), grpcSessionContextKey, session)
+
+	tests := []struct {
+		name  string
+		call  func() error
+		field string
+	}{
+		{"invalid sort", func() error {
+			_, err := service.List(ctx, &quotepb.ListQuotesRequest{Sort: "nope"})
+			return err
+		}, "sort"},
+		{"invalid tags mode", func() error {
+			_, err := service.List(ctx, &quotepb.ListQuotesRequest{TagsMode: "some"})
+			return err
+		}, "tags_mode"},
+		{"invalid date", func() error {
+			_, err := service.Today(ctx, &quotepb.TodayRequest{Date: "tomorrow"})
+			return err
+		}, "date"},
+	}
+
+	for _, tt := range tests {
+		st := status.Convert(tt.call())
+		if st.Code() != codes.InvalidArgument {
+			t.Errorf("%s: expected: %s, got: %s", tt.name, codes.InvalidArgument, st.Code())
+			continue
+		}
+		var fields []string
+		for _, detail := range st.Details() {
+			if badRequest, ok := detail.(*errdetails.BadRequest); ok {
+				for _, violation := range badRequest.FieldViolations {
+					fields = append(fields, violation.Field)
+				}
+			}
+		}
+		if len(fields) != 1 || fields[0] != tt.field {
+			t.Errorf("%s: expected: %q, got: %q", tt.name, tt.field, fields)
+		}
+	}
+}
@@ -9,7 +9,9 @@ import (
 	"net/url"
 	"strconv"
 	"strings"
+	"time"
 
+	"github.com/2016114132/qod/internal/data"
 	"github.com/2016114132/qod/internal/validator"
 
 	"github.com/julienschmidt/httprouter"
@@ -17,6 +19,9 @@ import (
 
 type envelope map[string]any
 
+// what is the max size of the request body (250KB seems reasonable)
+const jsonMaxBytes = 256_000
+
 func (a *application) writeJSON(w http.ResponseWriter,
 	status int, data envelope,
 	headers http.Header) error {
@@ -47,9 +52,7 @@ func (a *application) readJSON(w http.ResponseWriter,
 	r *http.Request,
 	destination any) error {
 
-	// what is the max size of the request body (250KB seems reasonable)
-	maxBytes := 256_000
-	r.Body = http.MaxBytesReader(w, r.Body, int64(maxBytes))
+	r.Body = http.MaxBytesReader(w, r.Body, jsonMaxBytes)
 	// our decoder will check for unknown fields
 	dec := json.NewDecoder(r.Body)
 	dec.DisallowUnknownFields()
@@ -58,48 +61,7 @@ func (a *application) readJSON(w http.ResponseWriter,
 
 	// err := json.NewDecoder(r.Body).Decode(destination)
 	if err != nil {
-		// check for the different errors
-		var syntaxError *json.SyntaxError
-		var unmarshalTypeError *json.UnmarshalTypeError
-		var invalidUnmarshalError *json.InvalidUnmarshalError
-		var maxBytesError *http.MaxBytesError
-
-		switch {
-		case errors.As(err, &syntaxError):
-			return fmt.Errorf("the body contains badly-formed JSON (at character %d)", syntaxError.Offset)
-
-			// Decode can also send back an io error message
-		case errors.Is(err, io.ErrUnexpectedEOF):
-			return errors.New("the body contains badly-formed JSON")
-
-		case errors.As(err, &unmarshalTypeError):
-			if unmarshalTypeError.Field != "" {
-				return fmt.Errorf("the body contains the incorrect JSON type for field %q",
-					unmarshalTypeError.Field)
-			}
-			return fmt.Errorf("the body contains the incorrect  JSON type (at character %d)",
-				unmarshalTypeError.Offset)
-
-		case errors.Is(err, io.EOF):
-			return errors.New("the body must not be empty")
-
-			// check for unknown field error
-		case strings.HasPrefix(err.Error(), "json: unknown field "):
-			fieldName := strings.TrimPrefix(err.Error(),
-				"json: unknown field ")
-			return fmt.Errorf("body contains unknown key %s", fieldName)
-
-		// does the body exceed our limit of 250KB?
-		case errors.As(err, &maxBytesError):
-			return fmt.Errorf("the body must not be larger than %d bytes", maxBytesError.Limit)
-
-		// the programmer messed up
-		case errors.As(err, &invalidUnmarshalError):
-			panic(err)
-			// some other type of error
-		default:
-			return err
-		}
+		return a.jsonDecodeError(err)
 	}
 
 	//Let's lastly check if there is any data after
@@ -116,6 +78,54 @@ func (a *application) readJSON(w http.ResponseWriter,
 	return nil
 }
 
+// Turn the errors from decoding a JSON body into messages that we can
+// send back to the client. Shared by readJSON() and the bulk import,
+// which decodes one value at a time
+func (a *application) jsonDecodeError(err error) error {
+	// check for the different errors
+	var syntaxError *json.SyntaxError
+	var unmarshalTypeError *json.UnmarshalTypeError
+	var invalidUnmarshalError *json.InvalidUnmarshalError
+	var maxBytesError *http.MaxBytesError
+
+	switch {
+	case errors.As(err, &syntaxError):
+		return fmt.Errorf("the body contains badly-formed JSON (at character %d)", syntaxError.Offset)
+
+		// Decode can also send back an io error message
+	case errors.Is(err, io.ErrUnexpectedEOF):
+		return errors.New("the body contains badly-formed JSON")
+
+	case errors.As(err, &unmarshalTypeError):
+		if unmarshalTypeError.Field != "" {
+			return fmt.Errorf("the body contains the incorrect JSON type for field %q",
+				unmarshalTypeError.Field)
+		}
+		return fmt.Errorf("the body contains the incorrect  JSON type (at character %d)",
+			unmarshalTypeError.Offset)
+
+	case errors.Is(err, io.EOF):
+		return errors.New("the body must not be empty")
+
+		// check for unknown field error
+	case strings.HasPrefix(err.Error(), "json: unknown field "):
+		fieldName := strings.TrimPrefix(err.Error(),
+			"json: unknown field ")
+		return fmt.Errorf("body contains unknown key %s", fieldName)
+
+	// does the body exceed our limit? (250KB for readJSON)
+	case errors.As(err, &maxBytesError):
+		return fmt.Errorf("the body must not be larger than %d bytes", maxBytesError.Limit)
+
+	// the programmer messed up
+	case errors.As(err, &invalidUnmarshalError):
+		panic(err)
+		// some other type of error
+	default:
+		return err
+	}
+}
+
 func (a *application) readIDParam(r *http.Request) (int64, error) {
 
 	// Get the URL parameters
@@ -129,6 +139,78 @@ func (a *application) readIDParam(r *http.Request) (int64, error) {
 	return id, nil
 }
 
+// Read the version of a quote from the URL, /v1/quotes/:id/revisions/:version
+func (a *application) readVersionParam(r *http.Request) (int32, error) {
+
+	params := httprouter.ParamsFromContext(r.Context())
+	version, err := strconv.ParseInt(params.ByName("version"), 10, 32)
+	if err != nil || version < 1 {
+		return 0, errors.New("invalid version parameter")
+	}
+
+	return int32(version), nil
+}
+
+// Read a calendar day (YYYY-MM-DD) from the URL, /v1/schedule/:date
+func (a *application) readDateParam(r *http.Request) (string, error) {
+
+	params := httprouter.ParamsFromContext(r.Context())
+	date := params.ByName("date")
+	_, err := time.Parse("2006-01-02", date)
+	if err != nil {
+		return "", errors.New("invalid date parameter")
+	}
+
+	return date, nil
+}
+
+// The ETag of a quote is its version. Each edit gets a new version so
+// the ETag changes whenever the quote does. render() adds the format
+// that the quote is sent in ("3-json", "3-xml")
+func quoteETag(quote *data.Quote) string {
+	return fmt.Sprintf(`"%d"`, quote.Version)
+}
+
+// The ETag of a response in a format. A strong ETag must be different
+// for each format since the bodies are, a weak one (the hash of the
+// body) already is
+func formatETag(etag string, format responseFormat) string {
+	if !strings.HasPrefix(etag, `"`) {
+		return etag
+	}
+	return strings.TrimSuffix(etag, `"`) + "-" + format.name + `"`
+}
+
+// Check the If-Match header of the request against the current ETag.
+// Without the header there is nothing to check, so the request may go
+// ahead. The ETag of the quote in any format will do, the format the
+// client got it in doesn't matter. Weak ETags (W/"...") never match
+// since If-Match needs an exact match
+func (a *application) ifMatch(r *http.Request, etag string) bool {
+	header := r.Header.Get("If-Match")
+	if header == "" {
+		return true
+	}
+	for _, candidate := range strings.Split(header, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if candidate == "*" {
+			return true
+		}
+		if strings.HasPrefix(candidate, "W/") || strings.HasPrefix(etag, "W/") {
+			continue
+		}
+		if candidate == etag {
+			return true
+		}
+		for _, format := range responseFormats {
+			if candidate == formatETag(etag, format) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
 func (a *application) getSingleQueryParameter(
 	queryParameters url.Values,
 	key string,
@@ -154,22 +236,10 @@ func (a *application) getMultipleQueryParameters(
 	return strings.Split(result, ",")
 }
 
-// this method can cause a validation error when trying to convert the
-// string to a valid integer value
-// func (a *application) getSingleIntegerParameter(
-// 	queryParameters url.Values,
-// 	key string,
-// 	defaultValue int,
-// 	v *validator.Validator) int {
-// 	result := queryParameters.Get(key)
-// 	if result == "" {
-// 		return defaultValue
-// 	}
-// 	return strings.Split(result, ",")
-// }
-
-// this method can cause a validation error when trying to convert the
-// string to a valid integer value
+// Read an optional integer query parameter. The routes check their query
+// parameters against the OpenAPI document (validateRequest()) first, but
+// gRPC and GraphQL don't go through it, so a value that is not an
+// integer is still reported
 func (a *application) getSingleIntegerParameter(
 	queryParameters url.Values,
 	key string,
@@ -179,7 +249,6 @@ func (a *application) getSingleIntegerParameter(
 	if result == "" {
 		return defaultValue
 	}
-	// try to convert to an integer
 	intValue, err := strconv.Atoi(result)
 	if err != nil {
 		v.AddError(key, "must be an integer value")
@@ -189,6 +258,77 @@ func (a *application) getSingleIntegerParameter(
 	return intValue
 }
 
+// Read an optional true/false query parameter, reported like the integers
+func (a *application) getBoolParameter(
+	queryParameters url.Values,
+	key string,
+	defaultValue bool,
+	v *validator.Validator) bool {
+
+	result := queryParameters.Get(key)
+	if result == "" {
+		return defaultValue
+	}
+	boolValue, err := strconv.ParseBool(result)
+	if err != nil {
+		v.AddError(key, "must be a boolean value")
+		return defaultValue
+	}
+	return boolValue
+}
+
+// Read an optional point in time, either RFC 3339 or just a day
+// (YYYY-MM-DD, midnight UTC). We get nil when the parameter is missing
+func (a *application) getTimeParameter(
+	queryParameters url.Values,
+	key string,
+	v *validator.Validator) *time.Time {
+
+	result := queryParameters.Get(key)
+	if result == "" {
+		return nil
+	}
+	for _, layout := range []string{time.RFC3339, "2006-01-02"} {
+		value, err := time.Parse(layout, result)
+		if err == nil {
+			return &value
+		}
+	}
+	v.AddError(key, "must be a date (YYYY-MM-DD) or an RFC 3339 time")
+	return nil
+}
+
+// Read the optional 'date' (YYYY-MM-DD) and 'tz' (IANA time zone name)
+// query parameters. Without a date we use today's date in that time zone,
+// and without a time zone we use UTC
+func (a *application) getDayParameters(
+	queryParameters url.Values,
+	v *validator.Validator) time.Time {
+
+	location, err := time.LoadLocation(a.getSingleQueryParameter(
+		queryParameters, "tz", "UTC"))
+	if err != nil {
+		v.AddError("tz", "must be a valid IANA time zone name")
+		location = time.UTC
+	}
+	today := time.Now().In(location)
+	today = time.Date(today.Year(), today.Month(), today.Day(), 0, 0, 0, 0, location)
+
+	result := queryParameters.Get("date")
+	if result == "" {
+		return today
+	}
+	day, err := time.ParseInLocation("2006-01-02", result, location)
+	if err != nil {
+		v.AddError("date", "must be a date in the format YYYY-MM-DD")
+		return today
+	}
+	// we can show the quote for past days but not for days to come
+	v.Check(!day.After(today), "date", "must not be in the future")
+
+	return day
+}
+
 // Accept a function and run it in the background also recover from any panic
 func (a *application) background(fn func()) {
 	a.wg.Add(1) // Use a wait group to ensure all goroutines finish before we exit
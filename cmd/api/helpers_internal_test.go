@@ -0,0 +1,91 @@
+// Filename: cmd/api/helpers_internal_test.go
+
+package main
+
+import (
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/2016114132/qod/internal/validator"
+)
+
+func TestIfMatch(t *testing.T) {
+	app := &application{}
+	etag := `"3"`
+
+	tests := []struct {
+		name   string
+		header string
+		want   bool
+	}{
+		{"no header", "", true},
+		{"same version", `"3"`, true},
+		{"any version", "*", true},
+		{"one of many", `"1", "3"`, true},
+		{"same version as json", `"3-json"`, true},
+		{"same version as xml", `"3-xml"`, true},
+		{"old version", `"2"`, false},
+		{"old version as json", `"2-json"`, false},
+		{"weak tag", `W/"3"`, false},
+	}
+
+	for _, tt := range tests {
+		r := httptest.NewRequest("PATCH", "/v1/quotes/1", nil)
+		if tt.header != "" {
+			r.Header.Set("If-Match", tt.header)
+		}
+		got := app.ifMatch(r, etag)
+		if got != tt.want {
+			t.Errorf("%s: expected: %v, got: %v", tt.name, tt.want, got)
+		}
+	}
+}
+
+func TestGetSingleIntegerParameter(t *testing.T) {
+	app := &application{}
+
+	tests := []struct {
+		query string
+		want  int
+		err   string
+	}{
+		{"", 5, ""},
+		{"limit=7", 7, ""},
+		{"limit=abc", 5, "must be an integer value"},
+		{"limit=1.5", 5, "must be an integer value"},
+	}
+
+	for _, tt := range tests {
+		queryParameters, _ := url.ParseQuery(tt.query)
+		v := validator.New()
+		got := app.getSingleIntegerParameter(queryParameters, "limit", 5, v)
+		if got != tt.want || v.Errors["limit"] != tt.err {
+			t.Errorf("%s: expected: %d %q, got: %d %q", tt.query, tt.want, tt.err, got, v.Errors["limit"])
+		}
+	}
+}
+
+func TestGetBoolParameter(t *testing.T) {
+	app := &application{}
+
+	tests := []struct {
+		query string
+		want  bool
+		err   string
+	}{
+		{"", false, ""},
+		{"partial=true", true, ""},
+		{"partial=0", false, ""},
+		{"partial=yes", false, "must be a boolean value"},
+	}
+
+	for _, tt := range tests {
+		queryParameters, _ := url.ParseQuery(tt.query)
+		v := validator.New()
+		got := app.getBoolParameter(queryParameters, "partial", false, v)
+		if got != tt.want || v.Errors["partial"] != tt.err {
+			t.Errorf("%s: expected: %v %q, got: %v %q", tt.query, tt.want, tt.err, got, v.Errors["partial"])
+		}
+	}
+}
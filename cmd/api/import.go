@@ -0,0 +1,400 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/2016114132/qod/internal/data"
+	"github.com/2016114132/qod/internal/validator"
+)
+
+// The largest file we accept for a bulk import (10MB). Just like with
+// readJSON() the body goes through a MaxBytesReader. The rows are decoded
+// one at a time, so we only hold on to the rows and not the file itself
+const importMaxBytes = 10_000_000
+
+// The most quotes that a single import may contain
+const importMaxRows = 10_000
+
+// One quote from the import file
+type importRow struct {
+	Content string   `json:"content"`
+	Author  string   `json:"author"`
+	Tags    []string `json:"tags"`
+	// what is wrong with a row that the reader could not make sense
+	// of, like a CSV line with the wrong number of fields
+	invalid map[string]string
+}
+
+// Hands out the rows of an import file one at a time. It returns
+// io.EOF after the last row
+type importReader interface {
+	next() (*importRow, error)
+}
+
+// What happened to the rows of an import. Rows are numbered from 1,
+// not counting the CSV header
+type importSummary struct {
+	Created     int                `json:"created"`
+	Skipped     int                `json:"skipped"`
+	Failed      int                `json:"failed"`
+	SkippedRows []importSkippedRow `json:"skipped_rows"`
+	FailedRows  []importFailedRow  `json:"failed_rows"`
+}
+
+// a row that we already have as a quote
+type importSkippedRow struct {
+	Row             int   `json:"row"`
+	ExistingQuoteID int64 `json:"existing_quote_id"`
+}
+
+// a row that is not a valid quote
+type importFailedRow struct {
+	Row    int               `json:"row"`
+	Errors map[string]string `json:"errors"`
+}
+
+// a row that is a valid quote, ready to be saved
+type importValidRow struct {
+	number int
+	quote  *data.Quote
+}
+
+// Add many quotes at once from a CSV file (text/csv, with a header row
+// naming the content, author and tags columns), a JSON array of quotes
+// (application/json) or one JSON quote per line (application/x-ndjson).
+// Quotes that we already have are skipped. By default nothing is saved
+// if any row is invalid; with partial=true the valid rows are saved and
+// the invalid ones are reported. The quotes are saved in batches of
+// data.ImportBatchSize, so if saving fails part way the earlier batches
+// stay. Importing the file again is safe, those quotes are skipped
+func (a *application) importQuotesHandler(w http.ResponseWriter,
+	r *http.Request) {
+	v := validator.New()
+	partial := a.getBoolParameter(r.URL.Query(), "partial", false, v)
+	if !v.IsEmpty() {
+		a.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	r.Body = http.MaxBytesReader(w, r.Body, importMaxBytes)
+	rows, err := a.newImportReader(r)
+	if err != nil {
+		a.unsupportedMediaTypeResponse(w, r)
+		return
+	}
+
+	// a large file takes longer to send than the server timeouts allow
+	controller := http.NewResponseController(w)
+	deadline := time.Now().Add(data.ImportTimeout)
+	_ = controller.SetReadDeadline(deadline)
+	_ = controller.SetWriteDeadline(deadline)
+
+	user := a.contextGetUser(r)
+	summary := importSummary{
+		SkippedRows: []importSkippedRow{},
+		FailedRows:  []importFailedRow{},
+	}
+
+	// read the whole file before we go near the database, a slow upload
+	// must not keep a transaction open
+	validRows := []importValidRow{}
+	number := 0
+	for {
+		row, err := rows.next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			a.badRequestResponse(w, r, err)
+			return
+		}
+		number++
+		if number > importMaxRows {
+			a.badRequestResponse(w, r,
+				fmt.Errorf("the body must not contain more than %d quotes", importMaxRows))
+			return
+		}
+		if row.invalid != nil {
+			summary.Failed++
+			summary.FailedRows = append(summary.FailedRows,
+				importFailedRow{Row: number, Errors: row.invalid})
+			continue
+		}
+
+		quote := &data.Quote{
+			Content:   row.Content,
+			Author:    row.Author,
+			Tags:      data.NormalizeTags(row.Tags),
+			CreatedBy: &user.ID,
+		}
+		v := validator.New()
+		data.ValidateQuote(v, quote)
+		if !v.IsEmpty() {
+			summary.Failed++
+			summary.FailedRows = append(summary.FailedRows,
+				importFailedRow{Row: number, Errors: v.Errors})
+			continue
+		}
+		validRows = append(validRows, importValidRow{number: number, quote: quote})
+	}
+
+	if number == 0 {
+		a.badRequestResponse(w, r, errors.New("the body must contain at least one quote"))
+		return
+	}
+
+	if summary.Failed > 0 && !partial {
+		message := envelope{
+			"message": "no quotes were imported since some rows are invalid, fix them or use partial=true",
+			"import":  summary,
+		}
+		a.errorResponseJSON(w, r, http.StatusUnprocessableEntity, message)
+		return
+	}
+
+	for start := 0; start < len(validRows); start += data.ImportBatchSize {
+		end := min(start+data.ImportBatchSize, len(validRows))
+		imported, err := a.importBatch(validRows[start:end], &summary)
+		if err != nil {
+			a.serverErrorResponse(w, r, err)
+			return
+		}
+		for _, quote := range imported {
+			a.queueWebhooks(r, data.WebhookPayload{
+				Event:   data.QuoteCreated,
+				QuoteID: quote.ID,
+				Quote:   quote,
+			})
+		}
+	}
+
+	data := envelope{
+		"import": summary,
+	}
+	err = a.writeJSON(w, http.StatusOK, data, nil)
+	if err != nil {
+		a.serverErrorResponse(w, r, err)
+	}
+}
+
+// Save a batch of the rows in one transaction and return the quotes
+// that were created. The rows that we already have as quotes are added
+// to the skipped rows of the summary
+func (a *application) importBatch(rows []importValidRow,
+	summary *importSummary) ([]*data.Quote, error) {
+	quoteImport, err := a.quoteModel.BeginImport()
+	if err != nil {
+		return nil, err
+	}
+	// does nothing once the batch is committed
+	defer quoteImport.Rollback()
+
+	imported := []*data.Quote{}
+	skipped := []importSkippedRow{}
+	for _, row := range rows {
+		quote := row.quote
+		// this also finds the quotes from earlier rows of the file
+		existing, err := quoteImport.FindDuplicate(quote.Content)
+		switch {
+		case err == nil:
+			skipped = append(skipped,
+				importSkippedRow{Row: row.number, ExistingQuoteID: existing.ID})
+			continue
+		case !errors.Is(err, data.ErrRecordNotFound):
+			return nil, err
+		}
+
+		err = quoteImport.Insert(quote)
+		if err != nil {
+			return nil, err
+		}
+		imported = append(imported, quote)
+	}
+
+	err = quoteImport.Commit()
+	if err != nil {
+		return nil, err
+	}
+	// only counted once the batch is saved
+	summary.Created += len(imported)
+	summary.Skipped += len(skipped)
+	summary.SkippedRows = append(summary.SkippedRows, skipped...)
+
+	return imported, nil
+}
+
+// Pick the reader for the format of the body, going by its Content-Type
+func (a *application) newImportReader(r *http.Request) (importReader, error) {
+	mediaType, _, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if err != nil {
+		return nil, err
+	}
+
+	switch mediaType {
+	case "text/csv":
+		reader := csv.NewReader(r.Body)
+		reader.ReuseRecord = true
+		// csvImportReader checks the number of fields of each row
+		reader.FieldsPerRecord = -1
+		return &csvImportReader{reader: reader}, nil
+	case "application/json":
+		dec := json.NewDecoder(r.Body)
+		dec.DisallowUnknownFields()
+		return &jsonImportReader{app: a, dec: dec}, nil
+	case "application/x-ndjson", "application/ndjson":
+		dec := json.NewDecoder(r.Body)
+		dec.DisallowUnknownFields()
+		return &ndjsonImportReader{app: a, dec: dec}, nil
+	default:
+		return nil, fmt.Errorf("unsupported media type %q", mediaType)
+	}
+}
+
+// Reads a JSON array of quotes, one element at a time
+type jsonImportReader struct {
+	app     *application
+	dec     *json.Decoder
+	started bool
+}
+
+func (ir *jsonImportReader) next() (*importRow, error) {
+	if !ir.started {
+		ir.started = true
+		token, err := ir.dec.Token()
+		if err != nil {
+			return nil, ir.app.jsonDecodeError(err)
+		}
+		if token != json.Delim('[') {
+			return nil, errors.New("the body must be a JSON array of quotes")
+		}
+	}
+
+	if !ir.dec.More() {
+		// the closing ] and then nothing else
+		_, err := ir.dec.Token()
+		if err != nil {
+			return nil, ir.app.jsonDecodeError(err)
+		}
+		_, err = ir.dec.Token()
+		if !errors.Is(err, io.EOF) {
+			return nil, errors.New("the body must only contain a single JSON value")
+		}
+		return nil, io.EOF
+	}
+
+	var row importRow
+	err := ir.dec.Decode(&row)
+	if err != nil {
+		return nil, ir.app.jsonDecodeError(err)
+	}
+	return &row, nil
+}
+
+// Reads one JSON quote after another (newline-delimited JSON)
+type ndjsonImportReader struct {
+	app *application
+	dec *json.Decoder
+}
+
+func (ir *ndjsonImportReader) next() (*importRow, error) {
+	var row importRow
+	err := ir.dec.Decode(&row)
+	if errors.Is(err, io.EOF) {
+		return nil, io.EOF
+	}
+	if err != nil {
+		return nil, ir.app.jsonDecodeError(err)
+	}
+	return &row, nil
+}
+
+// Reads a CSV file. The header row says which column is which, so the
+// columns can come in any order. tags is optional and holds a
+// comma-separated list, just like the tags query parameter and the
+// CSV export
+type csvImportReader struct {
+	reader  *csv.Reader
+	columns map[string]int
+	fields  int // the number of columns in the header
+}
+
+func (ir *csvImportReader) next() (*importRow, error) {
+	if ir.columns == nil {
+		header, err := ir.reader.Read()
+		if errors.Is(err, io.EOF) {
+			return nil, errors.New("the body must not be empty")
+		}
+		if err != nil {
+			return nil, csvError(err)
+		}
+		ir.fields = len(header)
+		ir.columns = make(map[string]int)
+		for i, name := range header {
+			name = strings.ToLower(strings.TrimSpace(name))
+			// a file from the export can be imported as it is, the
+			// columns that we set ourselves are left out
+			if validator.PermittedValue(name, "id", "author_id", "created_by",
+				"created_at", "updated_at", "version") {
+				continue
+			}
+			if !validator.PermittedValue(name, "content", "author", "tags") {
+				return nil, fmt.Errorf("the CSV header contains unknown column %q", name)
+			}
+			ir.columns[name] = i
+		}
+		for _, name := range []string{"content", "author"} {
+			if _, found := ir.columns[name]; !found {
+				return nil, fmt.Errorf("the CSV header must contain a %q column", name)
+			}
+		}
+	}
+
+	record, err := ir.reader.Read()
+	if errors.Is(err, io.EOF) {
+		return nil, io.EOF
+	}
+	if err != nil {
+		return nil, csvError(err)
+	}
+
+	// a row that doesn't line up with the header is reported like a
+	// quote that is not valid, not as a broken file
+	if len(record) != ir.fields {
+		return &importRow{invalid: map[string]string{
+			"row": fmt.Sprintf("must contain %d fields, not %d", ir.fields, len(record)),
+		}}, nil
+	}
+
+	row := &importRow{
+		Content: record[ir.columns["content"]],
+		Author:  record[ir.columns["author"]],
+	}
+	if i, found := ir.columns["tags"]; found && record[i] != "" {
+		row.Tags = strings.Split(record[i], ",")
+	}
+	return row, nil
+}
+
+// the CSV counterpart of jsonDecodeError()
+func csvError(err error) error {
+	var maxBytesError *http.MaxBytesError
+	var parseError *csv.ParseError
+
+	switch {
+	case errors.As(err, &maxBytesError):
+		return fmt.Errorf("the body must not be larger than %d bytes", maxBytesError.Limit)
+	case errors.As(err, &parseError):
+		return fmt.Errorf("the body contains badly-formed CSV (line %d: %v)",
+			parseError.Line, parseError.Err)
+	default:
+		return err
+	}
+}
@@ -0,0 +1,133 @@
+// Filename: cmd/api/import_internal_test.go
+
+package main
+
+import (
+	"errors"
+	"io"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestImportReaders(t *testing.T) {
+	app := &application{}
+
+	tests := []struct {
+		name        string
+		contentType string
+		body        string
+		want        []string // author: content, for each row
+	}{
+		{"csv", "text/csv",
+			"author,content,tags\nSeneca,Luck is preparation,\"life,luck\"\nYoda,Do or do not,\n",
+			[]string{"Seneca: Luck is preparation", "Yoda: Do or do not"}},
+		{"json array", "application/json; charset=utf-8",
+			`[{"content": "Do or do not", "author": "Yoda"}, {"content": "Be here now", "author": "Ram Dass"}]`,
+			[]string{"Yoda: Do or do not", "Ram Dass: Be here now"}},
+		{"ndjson", "application/x-ndjson",
+			"{\"content\": \"Do or do not\", \"author\": \"Yoda\"}\n{\"content\": \"Be here now\", \"author\": \"Ram Dass\"}\n",
+			[]string{"Yoda: Do or do not", "Ram Dass: Be here now"}},
+	}
+
+	for _, tt := range tests {
+		r := httptest.NewRequest("POST", "/v1/quotes/import", strings.NewReader(tt.body))
+		r.Header.Set("Content-Type", tt.contentType)
+
+		rows, err := app.newImportReader(r)
+		if err != nil {
+			t.Fatalf("%s: unexpected error: %v", tt.name, err)
+		}
+		got := []string{}
+		for {
+			row, err := rows.next()
+			if errors.Is(err, io.EOF) {
+				break
+			}
+			if err != nil {
+				t.Fatalf("%s: unexpected error: %v", tt.name, err)
+			}
+			got = append(got, row.Author+": "+row.Content)
+		}
+		if strings.Join(got, "|") != strings.Join(tt.want, "|") {
+			t.Errorf("%s: expected: %q, got: %q", tt.name, tt.want, got)
+		}
+	}
+}
+
+// A CSV line with too few or too many fields is a row that failed, the
+// rows after it are still read
+func TestImportReaderFieldCount(t *testing.T) {
+	app := &application{}
+
+	body := "author,content\nYoda\nSeneca,Luck is preparation,extra\nYoda,Do or do not\n"
+	r := httptest.NewRequest("POST", "/v1/quotes/import", strings.NewReader(body))
+	r.Header.Set("Content-Type", "text/csv")
+
+	rows, err := app.newImportReader(r)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want := []string{
+		"must contain 2 fields, not 1",
+		"must contain 2 fields, not 3",
+		"",
+	}
+	got := []string{}
+	for {
+		row, err := rows.next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		got = append(got, row.invalid["row"])
+	}
+	if strings.Join(got, "|") != strings.Join(want, "|") {
+		t.Errorf("expected: %q, got: %q", want, got)
+	}
+}
+
+func TestImportReaderErrors(t *testing.T) {
+	app := &application{}
+
+	tests := []struct {
+		name        string
+		contentType string
+		body        string
+	}{
+		{"csv without author", "text/csv", "content\nDo or do not\n"},
+		{"csv unknown column", "text/csv", "content,author,year\nDo or do not,Yoda,1980\n"},
+		{"json object", "application/json", `{"content": "Do or do not", "author": "Yoda"}`},
+		{"json unknown key", "application/json", `[{"content": "Do", "author": "Yoda", "year": 1980}]`},
+		{"json trailing data", "application/json", `[{"content": "Do", "author": "Yoda"}] []`},
+		{"ndjson bad line", "application/x-ndjson", "{\"content\": \"Do\", \"author\": \"Yoda\"}\n{\"content\": \n"},
+	}
+
+	for _, tt := range tests {
+		r := httptest.NewRequest("POST", "/v1/quotes/import", strings.NewReader(tt.body))
+		r.Header.Set("Content-Type", tt.contentType)
+
+		rows, err := app.newImportReader(r)
+		if err != nil {
+			t.Fatalf("%s: unexpected error: %v", tt.name, err)
+		}
+		for {
+			_, err = rows.next()
+			if err != nil {
+				break
+			}
+		}
+		if errors.Is(err, io.EOF) {
+			t.Errorf("%s: expected an error, got none", tt.name)
+		}
+	}
+
+	r := httptest.NewRequest("POST", "/v1/quotes/import", strings.NewReader("<quotes/>"))
+	r.Header.Set("Content-Type", "application/xml")
+	_, err := app.newImportReader(r)
+	if err == nil {
+		t.Errorf("xml: expected an error, got none")
+	}
+}
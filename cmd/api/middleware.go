@@ -65,13 +65,15 @@ func (a *application) enableCORS(next http.Handler) http.Handler {
 			for i := range a.config.cors.trustedOrigins {
 				if origin == a.config.cors.trustedOrigins[i] {
 					w.Header().Set("Access-Control-Allow-Origin", origin)
+					// let the browser read the ETag so it can send it back
+					w.Header().Set("Access-Control-Expose-Headers", "ETag")
 					// check if it is a Preflight CORS request
 					if r.Method == http.MethodOptions &&
 						r.Header.Get("Access-Control-Request-Method") != "" {
 						w.Header().Set("Access-Control-Allow-Methods",
 							"OPTIONS, PUT, PATCH, DELETE")
 						w.Header().Set("Access-Control-Allow-Headers",
-							"Authorization, Content-Type")
+							"Authorization, Content-Type, If-Match, If-None-Match")
 
 						// we need to send a 200 OK status. Also since there
 						// is no need to continue the middleware chain we
@@ -261,6 +263,20 @@ func (a *application) requirePermission(permissionCode string, next http.Handler
 
 }
 
+// Who the user is when they change a quote. Whether they may change a
+// given quote, theirs unless they are a moderator, is checked by the
+// model as part of the change (see data.QuoteEditor)
+func (a *application) quoteEditor(user *data.User) (data.QuoteEditor, error) {
+	permissions, err := a.permissionModel.GetAllForUser(user.ID)
+	if err != nil {
+		return data.QuoteEditor{}, err
+	}
+	return data.QuoteEditor{
+		ID:        user.ID,
+		Moderator: permissions.Include("quotes:moderate"),
+	}, nil
+}
+
 // We will create a new type right before we use it in our metrics middleware
 type metricsResponseWriter struct {
 	wrapped       http.ResponseWriter // the original http.ResponseWriter
@@ -311,6 +327,17 @@ func (mw *metricsResponseWriter) Write(b []byte) (int, error) {
 	return mw.wrapped.Write(b)
 }
 
+// Streaming responses (like the quote stream) need to send what they
+// wrote right away, so we pass Flush() on to the original writer
+func (mw *metricsResponseWriter) Flush() {
+	flusher, ok := mw.wrapped.(http.Flusher)
+	if !ok {
+		return
+	}
+	mw.headerWritten = true
+	flusher.Flush()
+}
+
 // We need a function to get the original http.ResponseWriter
 func (mw *metricsResponseWriter) Unwrap() http.ResponseWriter {
 	return mw.wrapped
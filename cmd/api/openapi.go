@@ -0,0 +1,38 @@
+package main
+
+import (
+	_ "embed"
+	"net/http"
+	"time"
+)
+
+// The OpenAPI document describes every route in routes(). It is kept by
+// hand, so a route that is added there must be added to it too (the
+// tests check). The docs page shows it in a browser
+var (
+	//go:embed "openapi/openapi.json"
+	openAPISpec []byte
+
+	//go:embed "openapi/docs.html"
+	docsPage []byte
+)
+
+func (a *application) openAPIHandler(w http.ResponseWriter, r *http.Request) {
+	headers := make(http.Header)
+	headers.Set("Cache-Control", "public, max-age=3600")
+
+	err := a.writeConditional(w, r, openAPISpec, "application/json", headers, time.Time{})
+	if err != nil {
+		a.serverErrorResponse(w, r, err)
+	}
+}
+
+func (a *application) docsHandler(w http.ResponseWriter, r *http.Request) {
+	headers := make(http.Header)
+	headers.Set("Cache-Control", "public, max-age=3600")
+
+	err := a.writeConditional(w, r, docsPage, "text/html; charset=utf-8", headers, time.Time{})
+	if err != nil {
+		a.serverErrorResponse(w, r, err)
+	}
+}
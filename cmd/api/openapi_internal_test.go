@@ -0,0 +1,203 @@
+// Filename: cmd/api/openapi_internal_test.go
+
+package main
+
+import (
+	"context"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"sort"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/getkin/kin-openapi/openapi3"
+)
+
+func loadOpenAPISpec(t *testing.T) *openapi3.T {
+	t.Helper()
+
+	spec, err := openapi3.NewLoader().LoadFromData(openAPISpec)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return spec
+}
+
+func TestOpenAPISpec(t *testing.T) {
+	spec := loadOpenAPISpec(t)
+
+	err := spec.Validate(context.Background())
+	if err != nil {
+		t.Errorf("expected: a valid OpenAPI document, got: %s", err)
+	}
+}
+
+// Every route in routes() must be in the OpenAPI document, and the
+// document must not have routes that don't exist
+func TestOpenAPICoversRoutes(t *testing.T) {
+	spec := loadOpenAPISpec(t)
+
+	documented := map[string]bool{}
+	for path, item := range spec.Paths.Map() {
+		for method := range item.Operations() {
+			documented[method+" "+path] = true
+		}
+	}
+
+	registered := registeredRoutes(t)
+	for _, route := range registered {
+		if !documented[route] {
+			t.Errorf("expected: %q in the OpenAPI document, got: nothing", route)
+		}
+		delete(documented, route)
+	}
+	for route := range documented {
+		t.Errorf("expected: %q in routes(), got: nothing", route)
+	}
+}
+
+// Every route that takes query parameters or a body has its handler
+// wrapped in validateRequest()
+func TestRoutesAreValidated(t *testing.T) {
+	spec := loadOpenAPISpec(t)
+
+	validated := validatedRoutes(t)
+	for path, item := range spec.Paths.Map() {
+		for method, operation := range item.Operations() {
+			takesInput := operation.RequestBody != nil
+			for _, parameter := range operation.Parameters {
+				if parameter.Value.In == "query" {
+					takesInput = true
+				}
+			}
+			route := method + " " + path
+			if takesInput && !validated[route] {
+				t.Errorf("expected: %q to use validateRequest(), got: it doesn't", route)
+			}
+		}
+	}
+}
+
+// The routes registered in routes.go as "METHOD /path/{param}". The
+// fixed segments of staticSegments() are routes of their own
+func registeredRoutes(t *testing.T) []string {
+	t.Helper()
+
+	var routes []string
+	for route := range validatedRoutes(t) {
+		routes = append(routes, route)
+	}
+	sort.Strings(routes)
+	return routes
+}
+
+// The routes of routes.go and whether their handler goes through
+// validateRequest()
+func validatedRoutes(t *testing.T) map[string]bool {
+	t.Helper()
+
+	file, err := parser.ParseFile(token.NewFileSet(), "routes.go", nil, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	routes := map[string]bool{}
+	ast.Inspect(file, func(node ast.Node) bool {
+		call, ok := node.(*ast.CallExpr)
+		if !ok || !isRouterCall(call) {
+			return true
+		}
+		method := strings.ToUpper(strings.TrimPrefix(selectorName(call.Args[0]), "Method"))
+		path := stringLiteral(t, call.Args[1])
+
+		// the parameter route only counts when it isn't just there for
+		// the fixed segments
+		paramRoute := true
+		handler := call.Args[2]
+		ast.Inspect(call.Args[2], func(node ast.Node) bool {
+			segments, ok := node.(*ast.CallExpr)
+			if !ok || selectorName(segments.Fun) != "staticSegments" {
+				return true
+			}
+			param := ":" + stringLiteral(t, segments.Args[0])
+			for _, element := range segments.Args[1].(*ast.CompositeLit).Elts {
+				keyValue := element.(*ast.KeyValueExpr)
+				segment := stringLiteral(t, keyValue.Key)
+				route := method + " " + openAPIPath(strings.Replace(path, param, segment, 1))
+				routes[route] = callsValidateRequest(keyValue.Value)
+			}
+			if selectorName(segments.Args[2]) == "methodNotAllowedResponse" {
+				paramRoute = false
+			}
+			handler = segments.Args[2]
+			return false
+		})
+		if paramRoute {
+			routes[method+" "+openAPIPath(path)] = callsValidateRequest(handler)
+		}
+		return true
+	})
+
+	if len(routes) == 0 {
+		t.Fatal("expected: routes in routes.go, got: none")
+	}
+	return routes
+}
+
+func callsValidateRequest(handler ast.Expr) bool {
+	found := false
+	ast.Inspect(handler, func(node ast.Node) bool {
+		call, ok := node.(*ast.CallExpr)
+		if ok && selectorName(call.Fun) == "validateRequest" {
+			found = true
+		}
+		return !found
+	})
+	return found
+}
+
+// router.HandlerFunc(...) or router.Handler(...)
+func isRouterCall(call *ast.CallExpr) bool {
+	selector, ok := call.Fun.(*ast.SelectorExpr)
+	if !ok || len(call.Args) != 3 {
+		return false
+	}
+	receiver, ok := selector.X.(*ast.Ident)
+	return ok && receiver.Name == "router" &&
+		(selector.Sel.Name == "HandlerFunc" || selector.Sel.Name == "Handler")
+}
+
+func selectorName(expr ast.Expr) string {
+	selector, ok := expr.(*ast.SelectorExpr)
+	if !ok {
+		return ""
+	}
+	return selector.Sel.Name
+}
+
+func stringLiteral(t *testing.T, expr ast.Expr) string {
+	t.Helper()
+
+	literal, ok := expr.(*ast.BasicLit)
+	if !ok || literal.Kind != token.STRING {
+		t.Fatalf("expected: a string literal, got: %T", expr)
+	}
+	value, err := strconv.Unquote(literal.Value)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return value
+}
+
+// /v1/quotes/:id becomes /v1/quotes/{id}
+func openAPIPath(path string) string {
+	segments := strings.Split(path, "/")
+	for i, segment := range segments {
+		if strings.HasPrefix(segment, ":") {
+			segments[i] = "{" + segment[1:] + "}"
+		}
+	}
+	return strings.Join(segments, "/")
+}
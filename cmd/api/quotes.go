@@ -3,7 +3,11 @@ package main
 import (
 	"errors"
 	"fmt"
+	"math/rand/v2"
 	"net/http"
+	"net/url"
+	"strings"
+	"time"
 
 	// import the data package which contains the definition for Quote
 	"github.com/2016114132/qod/internal/data"
@@ -15,8 +19,10 @@ func (a *application) createQuoteHandler(w http.ResponseWriter,
 	// create a struct to hold a quote
 	// we use struct tags[``] to make the names display in lowercase
 	var incomingData struct {
-		Content string `json:"content"`
-		Author  string `json:"author"`
+		Content  string   `json:"content"`
+		Author   string   `json:"author"`
+		AuthorID *int64   `json:"author_id"`
+		Tags     []string `json:"tags"`
 	}
 	// perform the decoding
 	// err := json.NewDecoder(r.Body).Decode(&incomingData)
@@ -33,26 +39,61 @@ func (a *application) createQuoteHandler(w http.ResponseWriter,
 	quote := &data.Quote{
 		Content: incomingData.Content,
 		Author:  incomingData.Author,
+		Tags:    data.NormalizeTags(incomingData.Tags),
+	}
+	// Remember who added the quote, they are the only one (besides the
+	// moderators) who can change it later
+	user := a.contextGetUser(r)
+	quote.CreatedBy = &user.ID
+
+	// A known author can be given by id instead of by name
+	if incomingData.AuthorID != nil &&
+		!a.setQuoteAuthor(w, r, quote, *incomingData.AuthorID) {
+		return
 	}
 	// Initialize a Validator instance
 	v := validator.New()
 
 	// Do the validation
 	data.ValidateQuote(v, quote)
+	// Moderators can add a quote even if it looks like a duplicate
+	allowDuplicate := a.getBoolParameter(r.URL.Query(), "allow_duplicate", false, v)
 	if !v.IsEmpty() {
 		a.failedValidationResponse(w, r, v.Errors) // implemented later
 		return
 	}
 
-	// Add the quote to the database table
-	err = a.quoteModel.Insert(quote)
+	if allowDuplicate {
+		permissions, err := a.permissionModel.GetAllForUser(user.ID)
+		if err != nil {
+			a.serverErrorResponse(w, r, err)
+			return
+		}
+		if !permissions.Include("quotes:moderate") {
+			a.notPermittedResponse(w, r)
+			return
+		}
+		// Add the quote to the database table
+		err = a.quoteModel.Insert(quote)
+	} else {
+		// the same quote with different case, spacing or punctuation
+		// is most likely already here
+		var existing *data.Quote
+		existing, err = a.quoteModel.InsertUnique(quote)
+		if errors.Is(err, data.ErrDuplicateQuote) {
+			a.duplicateQuoteResponse(w, r, existing.ID)
+			return
+		}
+	}
 	if err != nil {
 		a.serverErrorResponse(w, r, err)
 		return
 	}
-
-	// for now display the result
-	fmt.Fprintf(w, "%+v\n", incomingData)
+	a.queueWebhooks(r, data.WebhookPayload{
+		Event:   data.QuoteCreated,
+		QuoteID: quote.ID,
+		Quote:   quote,
+	})
 
 	// Set a Location header. The path to the newly created quote
 	headers := make(http.Header)
@@ -62,7 +103,7 @@ func (a *application) createQuoteHandler(w http.ResponseWriter,
 	data := envelope{
 		"quote": quote,
 	}
-	err = a.writeJSON(w, http.StatusCreated, data, headers)
+	err = a.render(w, r, http.StatusCreated, data, headers)
 	if err != nil {
 		a.serverErrorResponse(w, r, err)
 		return
@@ -93,11 +134,19 @@ func (a *application) displayQuoteHandler(
 		}
 		return
 	}
+	// The ETag lets the client send the version it has back to us
+	// in an If-Match header when it wants to change the quote
+	headers := make(http.Header)
+	headers.Set("ETag", quoteETag(quote))
+	// only the client may cache it and it has to check with us
+	// (If-None-Match) before using its copy
+	headers.Set("Cache-Control", "private, no-cache")
+
 	// display the quote
 	data := envelope{
 		"quote": quote,
 	}
-	err = a.writeJSON(w, http.StatusOK, data, nil)
+	err = a.renderConditional(w, r, data, headers, quote.UpdatedAt)
 	if err != nil {
 		a.serverErrorResponse(w, r, err)
 		return
@@ -125,13 +174,20 @@ func (a *application) updateQuoteHandler(
 		}
 		return
 	}
+	// Don't overwrite changes the client has not seen yet
+	if !a.ifMatch(r, quoteETag(quote)) {
+		a.preconditionFailedResponse(w, r)
+		return
+	}
 	// Use our temporary incomingData struct to hold the data
 	// Note: I have changed the types to pointer to differentiate
 	// between the client leaving a field empty intentionally
 	// and the field not needing to be updated
 	var incomingData struct {
-		Content *string `json:"content"`
-		Author  *string `json:"author"`
+		Content  *string   `json:"content"`
+		Author   *string   `json:"author"`
+		AuthorID *int64    `json:"author_id"`
+		Tags     *[]string `json:"tags"`
 	}
 	// perform the decoding
 	err = a.readJSON(w, r, &incomingData)
@@ -145,8 +201,18 @@ func (a *application) updateQuoteHandler(
 		quote.Content = *incomingData.Content
 	}
 	// if incomingData.Author is nil, no update was provided
+	// a new author name means we have to look the author up again
 	if incomingData.Author != nil {
 		quote.Author = *incomingData.Author
+		quote.AuthorID = nil
+	}
+	if incomingData.AuthorID != nil &&
+		!a.setQuoteAuthor(w, r, quote, *incomingData.AuthorID) {
+		return
+	}
+	// the tags provided replace all the existing tags
+	if incomingData.Tags != nil {
+		quote.Tags = data.NormalizeTags(*incomingData.Tags)
 	}
 
 	// Before we write the updates to the DB let's validate
@@ -156,16 +222,41 @@ func (a *application) updateQuoteHandler(
 		a.failedValidationResponse(w, r, v.Errors)
 		return
 	}
-	// perform the update
-	err = a.quoteModel.Update(quote)
+	// perform the update. If someone else updated the quote since
+	// we read it we get an edit conflict. Only the user who added the
+	// quote or a moderator may change it
+	editor, err := a.quoteEditor(a.contextGetUser(r))
 	if err != nil {
 		a.serverErrorResponse(w, r, err)
 		return
 	}
+	err = a.quoteModel.Update(quote, editor)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			a.notFoundResponse(w, r)
+		case errors.Is(err, data.ErrNotPermitted):
+			a.notPermittedResponse(w, r)
+		case errors.Is(err, data.ErrEditConflict):
+			a.editConflictResponse(w, r)
+		default:
+			a.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+	a.queueWebhooks(r, data.WebhookPayload{
+		Event:   data.QuoteUpdated,
+		QuoteID: quote.ID,
+		Quote:   quote,
+	})
+
+	headers := make(http.Header)
+	headers.Set("ETag", quoteETag(quote))
+
 	data := envelope{
 		"quote": quote,
 	}
-	err = a.writeJSON(w, http.StatusOK, data, nil)
+	err = a.render(w, r, http.StatusOK, data, headers)
 	if err != nil {
 		a.serverErrorResponse(w, r, err)
 		return
@@ -180,22 +271,55 @@ func (a *application) deleteQuoteHandler(
 		a.notFoundResponse(w, r)
 		return
 	}
-	err = a.quoteModel.Delete(id)
+	// We only need the quote to check the If-Match header. The quote is
+	// then only deleted if it is still at the version that matched
+	var version int32
+	if r.Header.Get("If-Match") != "" {
+		quote, err := a.quoteModel.Get(id)
+		if err != nil {
+			switch {
+			case errors.Is(err, data.ErrRecordNotFound):
+				a.notFoundResponse(w, r)
+			default:
+				a.serverErrorResponse(w, r, err)
+			}
+			return
+		}
+		if !a.ifMatch(r, quoteETag(quote)) {
+			a.preconditionFailedResponse(w, r)
+			return
+		}
+		version = quote.Version
+	}
+	editor, err := a.quoteEditor(a.contextGetUser(r))
+	if err != nil {
+		a.serverErrorResponse(w, r, err)
+		return
+	}
+	err = a.quoteModel.Delete(id, version, editor)
 
 	if err != nil {
 		switch {
 		case errors.Is(err, data.ErrRecordNotFound):
 			a.notFoundResponse(w, r)
+		case errors.Is(err, data.ErrNotPermitted):
+			a.notPermittedResponse(w, r)
+		case errors.Is(err, data.ErrEditConflict):
+			a.editConflictResponse(w, r)
 		default:
 			a.serverErrorResponse(w, r, err)
 		}
 		return
 	}
+	a.queueWebhooks(r, data.WebhookPayload{
+		Event:   data.QuoteDeleted,
+		QuoteID: id,
+	})
 	// display the quote
 	data := envelope{
-		"message": "quote successfully deleted",
+		"message": "quote successfully moved to the trash",
 	}
-	err = a.writeJSON(w, http.StatusOK, data, nil)
+	err = a.render(w, r, http.StatusOK, data, nil)
 	if err != nil {
 		a.serverErrorResponse(w, r, err)
 	}
@@ -208,48 +332,23 @@ func (a *application) listQuotesHandler(
 	// Create a struct to hold the query parameters
 	// Later on we will add fields for pagination and sorting (filters)
 	var queryParametersData struct {
-		Content string
-		Author  string
+		data.QuoteFilters
 		data.Filters
 	}
-	// get the query parameters from the URL
-	queryParameters := r.URL.Query()
-
-	// Load the query parameters into our struct
-	queryParametersData.Content = a.getSingleQueryParameter(
-		queryParameters,
-		"content",
-		"")
-
-	queryParametersData.Author = a.getSingleQueryParameter(
-		queryParameters,
-		"author",
-		"")
 
 	// Create a new validator instance
 	v := validator.New()
 
-	queryParametersData.Filters.Page = a.getSingleIntegerParameter(
-		queryParameters, "page", 1, v)
-	queryParametersData.Filters.PageSize = a.getSingleIntegerParameter(
-		queryParameters, "page_size", 10, v)
-
-	queryParametersData.Filters.Sort = a.getSingleQueryParameter(
-		queryParameters, "sort", "id")
-
-	queryParametersData.Filters.SortSafeList = []string{"id", "author",
-		"-id", "-author"}
-
-	// Check if our filters are valid
-	data.ValidateFilters(v, queryParametersData.Filters)
+	// Load the query parameters into our struct
+	queryParametersData.QuoteFilters, queryParametersData.Filters =
+		a.getQuoteListing(r.URL.Query(), v)
 	if !v.IsEmpty() {
 		a.failedValidationResponse(w, r, v.Errors)
 		return
 	}
 
 	quotes, metadata, err := a.quoteModel.GetAll(
-		queryParametersData.Content,
-		queryParametersData.Author,
+		queryParametersData.QuoteFilters,
 		queryParametersData.Filters,
 	)
 
@@ -258,12 +357,252 @@ func (a *application) listQuotesHandler(
 		return
 	}
 
+	// No Last-Modified: a quote that is added or deleted changes the
+	// page without changing the time of the quotes on it. The ETag is
+	// a hash of the page so If-None-Match still works
+	headers := make(http.Header)
+	headers.Set("Cache-Control", "private, no-cache")
+
 	data := envelope{
 		"quotes":    quotes,
 		"@metadata": metadata,
 	}
-	err = a.writeJSON(w, http.StatusOK, data, nil)
+	err = a.renderConditional(w, r, data, headers, time.Time{})
 	if err != nil {
 		a.serverErrorResponse(w, r, err)
 	}
 }
+
+func (a *application) todayQuoteHandler(
+	w http.ResponseWriter,
+	r *http.Request) {
+	// Which day does the client want? By default it is today (UTC)
+	v := validator.New()
+	day := a.getDayParameters(r.URL.Query(), v)
+	if !v.IsEmpty() {
+		a.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	quote, featured, err := a.quoteForDay(day)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			a.notFoundResponse(w, r)
+		default:
+			a.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	// No Last-Modified: when the schedule changes we answer with another
+	// quote, which can be older than the one the client has. The ETag
+	// is a hash of the response so If-None-Match still works.
+	// Everyone gets the same quote so shared caches may keep it too. We
+	// keep it short since editors can still change the schedule
+	headers := make(http.Header)
+	headers.Set("Cache-Control", "public, max-age=300")
+
+	data := envelope{
+		"date":     day.Format("2006-01-02"),
+		"featured": featured,
+		"quote":    quote,
+	}
+	err = a.renderConditional(w, r, data, headers, time.Time{})
+	if err != nil {
+		a.serverErrorResponse(w, r, err)
+	}
+}
+
+// The quote for a day is the one our editors scheduled for it. If there
+// is none we fall back to the automatic selection, which our background
+// job saves for today so it never changes (see pickDailyQuotes()).
+// Nothing is written here, anyone may ask about any day. featured
+// reports whether an editor picked the quote
+func (a *application) quoteForDay(day time.Time) (*data.Quote, bool, error) {
+	schedule, err := a.scheduleModel.GetForDays([]time.Time{day})
+	if err != nil {
+		return nil, false, err
+	}
+	scheduled, found := schedule[day.Format("2006-01-02")]
+	// there are no quotes at all
+	if !found {
+		return nil, false, data.ErrRecordNotFound
+	}
+	return scheduled.Quote, !scheduled.Automatic, nil
+}
+
+// The quotes for many days, by day (YYYY-MM-DD), the same way as
+// quoteForDay() but in one query. A day is missing when there are no
+// quotes at all
+func (a *application) quotesForDays(days []time.Time) (map[string]*data.Quote, error) {
+	schedule, err := a.scheduleModel.GetForDays(days)
+	if err != nil {
+		return nil, err
+	}
+	quotes := make(map[string]*data.Quote, len(schedule))
+	for date, scheduled := range schedule {
+		quotes[date] = scheduled.Quote
+	}
+	return quotes, nil
+}
+
+func (a *application) randomQuotesHandler(
+	w http.ResponseWriter,
+	r *http.Request) {
+	queryParameters := r.URL.Query()
+
+	v := validator.New()
+
+	// The same search terms that listQuotesHandler uses
+	quoteFilters := a.getQuoteFilters(queryParameters, v)
+
+	count := a.getSingleIntegerParameter(queryParameters, "count", 1, v)
+	v.Check(count > 0, "count", "must be greater than zero")
+	v.Check(count <= 10, "count", "must be a maximum of 10")
+
+	// Without a seed we make one up. We send the seed back so that the
+	// client can ask for the same sequence again
+	seed := int64(a.getSingleIntegerParameter(queryParameters, "seed", int(rand.Int64()), v))
+
+	if !v.IsEmpty() {
+		a.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	rng := rand.New(rand.NewPCG(uint64(seed), 0))
+	quotes, err := a.quoteModel.GetRandom(quoteFilters, count, rng)
+	if err != nil {
+		a.serverErrorResponse(w, r, err)
+		return
+	}
+
+	data := envelope{
+		"quotes": quotes,
+		"seed":   seed,
+	}
+	err = a.render(w, r, http.StatusOK, data, nil)
+	if err != nil {
+		a.serverErrorResponse(w, r, err)
+	}
+}
+
+// Read the query parameters of the quote listing (GET /v1/quotes): the
+// search terms, page, page_size, sort and cursor. The filters are
+// validated too
+func (a *application) getQuoteListing(
+	queryParameters url.Values,
+	v *validator.Validator) (data.QuoteFilters, data.Filters) {
+
+	quoteFilters := a.getQuoteFilters(queryParameters, v)
+
+	var filters data.Filters
+	filters.Page = a.getSingleIntegerParameter(queryParameters, "page", 1, v)
+	filters.PageSize = a.getSingleIntegerParameter(queryParameters, "page_size", 10, v)
+	filters.Sort = a.getQuoteSort(queryParameters, quoteFilters, v)
+	filters.SortSafeList = quoteSortSafeList
+
+	// Deep pages are slow and page is capped, so clients can follow the
+	// next_cursor from the metadata instead. It can't be mixed with page
+	filters.Cursor = a.getSingleQueryParameter(queryParameters, "cursor", "")
+	if filters.Cursor != "" && queryParameters.Has("page") {
+		v.AddError("cursor", "must not be used together with page")
+	}
+
+	// Check if our filters are valid
+	data.ValidateFilters(v, filters)
+
+	return quoteFilters, filters
+}
+
+// The sort values that the quote listings accept
+var quoteSortSafeList = []string{"id", "author",
+	"created_at", "version", "length", "relevance",
+	"-id", "-author", "-created_at", "-version", "-length", "-relevance"}
+
+// Read the sort query parameter of the quote listings, id by default
+func (a *application) getQuoteSort(
+	queryParameters url.Values,
+	quoteFilters data.QuoteFilters,
+	v *validator.Validator) string {
+
+	sort := a.getSingleQueryParameter(queryParameters, "sort", "id")
+
+	// without a content search every quote is equally relevant
+	sortColumn := strings.TrimPrefix(sort, "-")
+	v.Check(sortColumn != "relevance" || quoteFilters.Content != "",
+		"sort", "relevance can only be used together with content")
+
+	return sort
+}
+
+// Read the search terms used to narrow down the quote listings:
+// content, author, author_id, tags (comma-separated), tags_mode (any|all),
+// created_after, created_before, author_exact, min_length, max_length
+// and fuzzy (true to allow typos in content and author)
+func (a *application) getQuoteFilters(
+	queryParameters url.Values,
+	v *validator.Validator) data.QuoteFilters {
+
+	var quoteFilters data.QuoteFilters
+
+	quoteFilters.Content = a.getSingleQueryParameter(queryParameters, "content", "")
+	quoteFilters.Author = a.getSingleQueryParameter(queryParameters, "author", "")
+	quoteFilters.AuthorID = int64(a.getSingleIntegerParameter(
+		queryParameters, "author_id", 0, v))
+	quoteFilters.Tags = data.NormalizeTags(a.getMultipleQueryParameters(
+		queryParameters, "tags", []string{}))
+
+	tagsMode := a.getSingleQueryParameter(queryParameters, "tags_mode", "any")
+	v.Check(validator.PermittedValue(tagsMode, "any", "all"), "tags_mode",
+		"must be either any or all")
+	quoteFilters.MatchAllTags = tagsMode == "all"
+
+	quoteFilters.CreatedAfter = a.getTimeParameter(queryParameters, "created_after", v)
+	quoteFilters.CreatedBefore = a.getTimeParameter(queryParameters, "created_before", v)
+	if quoteFilters.CreatedAfter != nil && quoteFilters.CreatedBefore != nil {
+		v.Check(quoteFilters.CreatedBefore.After(*quoteFilters.CreatedAfter),
+			"created_before", "must be later than created_after")
+	}
+
+	quoteFilters.AuthorExact = a.getSingleQueryParameter(queryParameters, "author_exact", "")
+
+	quoteFilters.Fuzzy = a.getBoolParameter(queryParameters, "fuzzy", false, v)
+
+	quoteFilters.MinLength = a.getSingleIntegerParameter(queryParameters, "min_length", 0, v)
+	quoteFilters.MaxLength = a.getSingleIntegerParameter(queryParameters, "max_length", 0, v)
+	v.Check(quoteFilters.MinLength >= 0, "min_length", "must not be negative")
+	v.Check(quoteFilters.MaxLength >= 0, "max_length", "must not be negative")
+	if quoteFilters.MaxLength > 0 {
+		v.Check(quoteFilters.MaxLength >= quoteFilters.MinLength, "max_length",
+			"must not be less than min_length")
+	}
+
+	return quoteFilters
+}
+
+// Make the author with the given id the author of the quote. If there
+// is no such author, or something went wrong, the response is sent and
+// we return false
+func (a *application) setQuoteAuthor(w http.ResponseWriter,
+	r *http.Request,
+	quote *data.Quote,
+	authorID int64) bool {
+
+	author, err := a.authorModel.Get(authorID)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			v := validator.New()
+			v.AddError("author_id", "must refer to an existing author")
+			a.failedValidationResponse(w, r, v.Errors)
+		default:
+			a.serverErrorResponse(w, r, err)
+		}
+		return false
+	}
+	quote.Author = author.Name
+	quote.AuthorID = &author.ID
+
+	return true
+}
@@ -0,0 +1,430 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/2016114132/qod/internal/data"
+	"github.com/vmihailenco/msgpack/v5"
+)
+
+// A format that we can send a response in
+type responseFormat struct {
+	name      string
+	mediaType string   // the Content-Type that we send
+	accepts   []string // the media types in the Accept header that ask for it
+	// can the response be written in this format? Only JSON, XML and
+	// MessagePack can hold anything
+	supports func(response envelope) bool
+}
+
+var (
+	formatJSON = responseFormat{
+		name:      "json",
+		mediaType: "application/json",
+		accepts:   []string{"application/json"},
+		supports:  func(envelope) bool { return true },
+	}
+	formatXML = responseFormat{
+		name:      "xml",
+		mediaType: "application/xml; charset=utf-8",
+		accepts:   []string{"application/xml", "text/xml"},
+		supports:  func(envelope) bool { return true },
+	}
+	formatMsgpack = responseFormat{
+		name:      "msgpack",
+		mediaType: "application/msgpack",
+		accepts:   []string{"application/msgpack", "application/x-msgpack", "application/vnd.msgpack"},
+		supports:  func(envelope) bool { return true },
+	}
+	// CSV is for lists of quotes only
+	formatCSV = responseFormat{
+		name:      "csv",
+		mediaType: "text/csv; charset=utf-8",
+		accepts:   []string{"text/csv"},
+		supports: func(response envelope) bool {
+			_, ok := response["quotes"].([]*data.Quote)
+			return ok
+		},
+	}
+	// plain text is for quotes and messages
+	formatText = responseFormat{
+		name:      "text",
+		mediaType: "text/plain; charset=utf-8",
+		accepts:   []string{"text/plain"},
+		supports: func(response envelope) bool {
+			_, err := encodeText(response)
+			return err == nil
+		},
+	}
+)
+
+// Every format, in the order we prefer them when the client likes
+// several of them just as much
+var responseFormats = []responseFormat{formatJSON, formatXML, formatMsgpack, formatCSV, formatText}
+
+// The formats that the response can be written in
+func formatsFor(data envelope) []responseFormat {
+	formats := []responseFormat{}
+	for _, format := range responseFormats {
+		if format.supports(data) {
+			formats = append(formats, format)
+		}
+	}
+	return formats
+}
+
+// Pick the format that the Accept header likes best out of the
+// candidates. Without an Accept header the client gets the first
+// candidate. We return false if the client accepts none of them
+func negotiateFormat(accept string, candidates []responseFormat) (responseFormat, bool) {
+	if strings.TrimSpace(accept) == "" {
+		return candidates[0], true
+	}
+
+	type mediaRange struct {
+		mediaType string
+		q         float64
+	}
+	ranges := []mediaRange{}
+	for _, part := range strings.Split(accept, ",") {
+		params := strings.Split(part, ";")
+		mr := mediaRange{mediaType: strings.ToLower(strings.TrimSpace(params[0])), q: 1}
+		for _, param := range params[1:] {
+			key, value, _ := strings.Cut(strings.TrimSpace(param), "=")
+			if strings.TrimSpace(key) == "q" {
+				q, err := strconv.ParseFloat(strings.TrimSpace(value), 64)
+				if err == nil {
+					mr.q = q
+				}
+			}
+		}
+		ranges = append(ranges, mr)
+	}
+
+	// the quality of a media type is the one of the most specific range
+	// that matches it: text/csv beats text/* which beats */*
+	quality := func(mediaType string) float64 {
+		mainType, _, _ := strings.Cut(mediaType, "/")
+		best, bestSpecificity := 0.0, 0
+		for _, mr := range ranges {
+			specificity := 0
+			switch mr.mediaType {
+			case mediaType:
+				specificity = 3
+			case mainType + "/*":
+				specificity = 2
+			case "*/*":
+				specificity = 1
+			}
+			if specificity > bestSpecificity {
+				best, bestSpecificity = mr.q, specificity
+			}
+		}
+		return best
+	}
+
+	var chosen responseFormat
+	chosenQ := 0.0
+	for _, candidate := range candidates {
+		for _, mediaType := range candidate.accepts {
+			q := quality(mediaType)
+			if q > chosenQ {
+				chosen, chosenQ = candidate, q
+			}
+		}
+	}
+	return chosen, chosenQ > 0
+}
+
+// Write the response in the format that the client asked for in its
+// Accept header (JSON if it did not ask). If we can't send the response
+// in any of the formats the client accepts it gets a 406
+func (a *application) render(w http.ResponseWriter,
+	r *http.Request,
+	status int,
+	data envelope,
+	headers http.Header) error {
+
+	format, ok := negotiateFormat(r.Header.Get("Accept"), formatsFor(data))
+	if !ok {
+		a.notAcceptableResponse(w, r)
+		return nil
+	}
+
+	return a.writeFormat(w, format, status, data, headers)
+}
+
+// Write the response in the given format. This is writeJSON() for all
+// of the formats
+func (a *application) writeFormat(w http.ResponseWriter,
+	format responseFormat,
+	status int,
+	data envelope,
+	headers http.Header) error {
+
+	body, err := encodeResponse(format, data)
+	if err != nil {
+		return err
+	}
+
+	for key, value := range headers {
+		w.Header()[key] = value
+	}
+	if etag := headers.Get("ETag"); etag != "" {
+		w.Header().Set("ETag", formatETag(etag, format))
+	}
+	// the same URL answers differently depending on the Accept header
+	w.Header().Add("Vary", "Accept")
+	w.Header().Set("Content-Type", format.mediaType)
+	w.WriteHeader(status)
+	_, err = w.Write(body)
+
+	return err
+}
+
+// Encode the response in the format
+func encodeResponse(format responseFormat, data envelope) ([]byte, error) {
+	switch format.name {
+	case "xml":
+		return encodeXML(data)
+	case "msgpack":
+		generic, err := toGeneric(data)
+		if err != nil {
+			return nil, err
+		}
+		return msgpack.Marshal(generic)
+	case "csv":
+		return encodeCSV(data)
+	case "text":
+		return encodeText(data)
+	default:
+		js, err := json.MarshalIndent(data, "", "\t")
+		if err != nil {
+			return nil, err
+		}
+		return append(js, '\n'), nil
+	}
+}
+
+// Turn the response into plain maps, slices, strings, numbers and bools
+// by going through JSON. That way every format has the same field names
+// and leaves out the same fields as the JSON response
+func toGeneric(data envelope) (any, error) {
+	js, err := json.Marshal(data)
+	if err != nil {
+		return nil, err
+	}
+	dec := json.NewDecoder(bytes.NewReader(js))
+	dec.UseNumber()
+
+	var generic any
+	err = dec.Decode(&generic)
+	if err != nil {
+		return nil, err
+	}
+	return convertNumbers(generic), nil
+}
+
+// json.Number becomes an int64 when it is a whole number, a float64 otherwise
+func convertNumbers(value any) any {
+	switch value := value.(type) {
+	case map[string]any:
+		for key, element := range value {
+			value[key] = convertNumbers(element)
+		}
+		return value
+	case []any:
+		for i, element := range value {
+			value[i] = convertNumbers(element)
+		}
+		return value
+	case json.Number:
+		if i, err := value.Int64(); err == nil {
+			return i
+		}
+		f, _ := value.Float64()
+		return f
+	default:
+		return value
+	}
+}
+
+// The response becomes a <response> element with an element per field.
+// The elements of a list are <item>s. Field names that are not valid
+// XML names, like @metadata, lose the characters that are not allowed
+func encodeXML(data envelope) ([]byte, error) {
+	generic, err := toGeneric(data)
+	if err != nil {
+		return nil, err
+	}
+
+	var buffer bytes.Buffer
+	buffer.WriteString(xml.Header)
+	enc := xml.NewEncoder(&buffer)
+	enc.Indent("", "\t")
+
+	err = encodeXMLElement(enc, "response", generic)
+	if err != nil {
+		return nil, err
+	}
+	err = enc.Flush()
+	if err != nil {
+		return nil, err
+	}
+	buffer.WriteByte('\n')
+
+	return buffer.Bytes(), nil
+}
+
+func encodeXMLElement(enc *xml.Encoder, name string, value any) error {
+	start := xml.StartElement{Name: xml.Name{Local: xmlName(name)}}
+	err := enc.EncodeToken(start)
+	if err != nil {
+		return err
+	}
+
+	switch value := value.(type) {
+	case map[string]any:
+		keys := make([]string, 0, len(value))
+		for key := range value {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			err = encodeXMLElement(enc, key, value[key])
+			if err != nil {
+				return err
+			}
+		}
+	case []any:
+		for _, element := range value {
+			err = encodeXMLElement(enc, "item", element)
+			if err != nil {
+				return err
+			}
+		}
+	case nil:
+		// an empty element
+	default:
+		err = enc.EncodeToken(xml.CharData(fmt.Sprint(value)))
+		if err != nil {
+			return err
+		}
+	}
+
+	return enc.EncodeToken(start.End())
+}
+
+// Keep the letters, digits, _ - and . of a name, and make sure it
+// starts with a letter or _
+func xmlName(name string) string {
+	var builder strings.Builder
+	for _, r := range name {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r == '_':
+			builder.WriteRune(r)
+		case (r >= '0' && r <= '9') || r == '-' || r == '.':
+			if builder.Len() == 0 {
+				builder.WriteRune('_')
+			}
+			builder.WriteRune(r)
+		}
+	}
+	if builder.Len() == 0 {
+		return "_"
+	}
+	return builder.String()
+}
+
+// A list of quotes as CSV, with the same columns as the CSV export
+func encodeCSV(response envelope) ([]byte, error) {
+	quotes, ok := response["quotes"].([]*data.Quote)
+	if !ok {
+		return nil, fmt.Errorf("csv: the response is not a list of quotes")
+	}
+
+	var buffer bytes.Buffer
+	exporter := newQuoteExporter("csv", &buffer)
+	err := exporter.begin()
+	if err != nil {
+		return nil, err
+	}
+	for _, quote := range quotes {
+		err = exporter.write(quote)
+		if err != nil {
+			return nil, err
+		}
+	}
+	err = exporter.end()
+	if err != nil {
+		return nil, err
+	}
+
+	return buffer.Bytes(), nil
+}
+
+// Quotes as "content" — author, one per line. Errors and messages are
+// written as they are, validation errors as field: problem lines
+func encodeText(response envelope) ([]byte, error) {
+	var buffer bytes.Buffer
+
+	writeQuote := func(quote *data.Quote) {
+		fmt.Fprintf(&buffer, "\"%s\" — %s\n", quote.Content, quote.Author)
+	}
+
+	switch {
+	case response["quote"] != nil:
+		quote, ok := response["quote"].(*data.Quote)
+		if !ok {
+			return nil, fmt.Errorf("text: unsupported quote")
+		}
+		writeQuote(quote)
+	case response["quotes"] != nil:
+		quotes, ok := response["quotes"].([]*data.Quote)
+		if !ok {
+			return nil, fmt.Errorf("text: unsupported quotes")
+		}
+		for _, quote := range quotes {
+			writeQuote(quote)
+		}
+	case response["error"] != nil:
+		switch message := response["error"].(type) {
+		case string:
+			fmt.Fprintln(&buffer, message)
+		case map[string]string:
+			keys := make([]string, 0, len(message))
+			for key := range message {
+				keys = append(keys, key)
+			}
+			sort.Strings(keys)
+			for _, key := range keys {
+				fmt.Fprintf(&buffer, "%s: %s\n", key, message[key])
+			}
+		case envelope:
+			text, ok := message["message"].(string)
+			if !ok {
+				return nil, fmt.Errorf("text: unsupported error")
+			}
+			fmt.Fprintln(&buffer, text)
+		default:
+			return nil, fmt.Errorf("text: unsupported error")
+		}
+	case response["message"] != nil:
+		message, ok := response["message"].(string)
+		if !ok {
+			return nil, fmt.Errorf("text: unsupported message")
+		}
+		fmt.Fprintln(&buffer, message)
+	default:
+		return nil, fmt.Errorf("text: unsupported response")
+	}
+
+	return buffer.Bytes(), nil
+}
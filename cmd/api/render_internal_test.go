@@ -0,0 +1,70 @@
+// Filename: cmd/api/render_internal_test.go
+
+package main
+
+import (
+	"testing"
+
+	"github.com/2016114132/qod/internal/data"
+)
+
+func TestNegotiateFormat(t *testing.T) {
+	quote := envelope{"quote": &data.Quote{Content: "Do or do not", Author: "Yoda"}}
+	quotes := envelope{"quotes": []*data.Quote{}}
+
+	tests := []struct {
+		name     string
+		accept   string
+		response envelope
+		want     string // "" means 406
+	}{
+		{"no header", "", quote, "json"},
+		{"anything", "*/*", quote, "json"},
+		{"xml", "application/xml", quote, "xml"},
+		{"old xml", "text/xml", quote, "xml"},
+		{"msgpack", "application/x-msgpack", quote, "msgpack"},
+		{"text", "text/plain", quote, "text"},
+		{"csv list", "text/csv", quotes, "csv"},
+		{"csv single quote", "text/csv", quote, ""},
+		{"csv or json", "text/csv, application/json;q=0.5", quote, "json"},
+		{"quality", "application/json;q=0.2, application/xml;q=0.9", quote, "xml"},
+		{"specific beats wildcard", "text/*;q=0.1, text/plain", quote, "text"},
+		{"refused", "application/json;q=0, */*;q=0.5", quote, "xml"},
+		{"unsupported", "application/pdf", quote, ""},
+	}
+
+	for _, tt := range tests {
+		format, ok := negotiateFormat(tt.accept, formatsFor(tt.response))
+		got := ""
+		if ok {
+			got = format.name
+		}
+		if got != tt.want {
+			t.Errorf("%s: expected: %q, got: %q", tt.name, tt.want, got)
+		}
+	}
+}
+
+func TestEncodeText(t *testing.T) {
+	tests := []struct {
+		name     string
+		response envelope
+		want     string
+	}{
+		{"quote", envelope{"quote": &data.Quote{Content: "Do or do not", Author: "Yoda"}},
+			"\"Do or do not\" — Yoda\n"},
+		{"error", envelope{"error": "not found"}, "not found\n"},
+		{"validation", envelope{"error": map[string]string{"b": "two", "a": "one"}},
+			"a: one\nb: two\n"},
+	}
+
+	for _, tt := range tests {
+		got, err := encodeText(tt.response)
+		if err != nil {
+			t.Fatalf("%s: unexpected error: %v", tt.name, err)
+		}
+		if string(got) != tt.want {
+			t.Errorf("%s: expected: %q, got: %q", tt.name, tt.want, string(got))
+		}
+	}
+}
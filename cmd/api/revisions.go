@@ -0,0 +1,192 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/2016114132/qod/internal/data"
+	"github.com/2016114132/qod/internal/validator"
+)
+
+// List the history of a quote, the newest version first
+func (a *application) listRevisionsHandler(w http.ResponseWriter,
+	r *http.Request) {
+	id, err := a.readIDParam(r)
+	if err != nil {
+		a.notFoundResponse(w, r)
+		return
+	}
+	// the history of a quote in the trash is not shown
+	_, err = a.quoteModel.Get(id)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			a.notFoundResponse(w, r)
+		default:
+			a.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	var filters data.Filters
+	queryParameters := r.URL.Query()
+
+	v := validator.New()
+
+	filters.Page = a.getSingleIntegerParameter(queryParameters, "page", 1, v)
+	filters.PageSize = a.getSingleIntegerParameter(queryParameters, "page_size", 10, v)
+	filters.Sort = a.getSingleQueryParameter(queryParameters, "sort", "-version")
+	filters.SortSafeList = []string{"version", "-version"}
+
+	data.ValidateFilters(v, filters)
+	if !v.IsEmpty() {
+		a.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	revisions, metadata, err := a.revisionModel.GetAllForQuote(id, filters)
+	if err != nil {
+		a.serverErrorResponse(w, r, err)
+		return
+	}
+
+	data := envelope{
+		"revisions": revisions,
+		"@metadata": metadata,
+	}
+	err = a.render(w, r, http.StatusOK, data, nil)
+	if err != nil {
+		a.serverErrorResponse(w, r, err)
+	}
+}
+
+func (a *application) displayRevisionHandler(w http.ResponseWriter,
+	r *http.Request) {
+	revision, ok := a.readRevision(w, r)
+	if !ok {
+		return
+	}
+	// just like the list, no history for a quote in the trash
+	_, err := a.quoteModel.Get(revision.QuoteID)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			a.notFoundResponse(w, r)
+		default:
+			a.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	data := envelope{
+		"revision": revision,
+	}
+	err = a.render(w, r, http.StatusOK, data, nil)
+	if err != nil {
+		a.serverErrorResponse(w, r, err)
+	}
+}
+
+// Put an old version of the quote back. This does not rewrite history,
+// the old content becomes the newest version of the quote
+func (a *application) revertQuoteHandler(w http.ResponseWriter,
+	r *http.Request) {
+	revision, ok := a.readRevision(w, r)
+	if !ok {
+		return
+	}
+	quote, err := a.quoteModel.Get(revision.QuoteID)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			a.notFoundResponse(w, r)
+		default:
+			a.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+	if !a.ifMatch(r, quoteETag(quote)) {
+		a.preconditionFailedResponse(w, r)
+		return
+	}
+
+	// the author is looked up again by name since it might have been
+	// linked to a different author back then
+	quote.Content = revision.Content
+	quote.Author = revision.Author
+	quote.AuthorID = nil
+
+	v := validator.New()
+	data.ValidateQuote(v, quote)
+	if !v.IsEmpty() {
+		a.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	editor, err := a.quoteEditor(a.contextGetUser(r))
+	if err != nil {
+		a.serverErrorResponse(w, r, err)
+		return
+	}
+	err = a.quoteModel.Update(quote, editor)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			a.notFoundResponse(w, r)
+		case errors.Is(err, data.ErrNotPermitted):
+			a.notPermittedResponse(w, r)
+		case errors.Is(err, data.ErrEditConflict):
+			a.editConflictResponse(w, r)
+		default:
+			a.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+	a.queueWebhooks(r, data.WebhookPayload{
+		Event:   data.QuoteUpdated,
+		QuoteID: quote.ID,
+		Quote:   quote,
+	})
+
+	headers := make(http.Header)
+	headers.Set("ETag", quoteETag(quote))
+
+	data := envelope{
+		"quote": quote,
+	}
+	err = a.render(w, r, http.StatusOK, data, headers)
+	if err != nil {
+		a.serverErrorResponse(w, r, err)
+	}
+}
+
+// Get the revision from /v1/quotes/:id/revisions/:version. If it does
+// not exist, or something went wrong, the response is sent and we
+// return false
+func (a *application) readRevision(w http.ResponseWriter,
+	r *http.Request) (*data.QuoteRevision, bool) {
+
+	id, err := a.readIDParam(r)
+	if err != nil {
+		a.notFoundResponse(w, r)
+		return nil, false
+	}
+	version, err := a.readVersionParam(r)
+	if err != nil {
+		a.notFoundResponse(w, r)
+		return nil, false
+	}
+
+	revision, err := a.revisionModel.Get(id, version)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			a.notFoundResponse(w, r)
+		default:
+			a.serverErrorResponse(w, r, err)
+		}
+		return nil, false
+	}
+
+	return revision, true
+}
@@ -17,19 +17,31 @@ func (app *application) routes() http.Handler {
 	// setup routes
 	router.HandlerFunc(http.MethodGet, "/v1/healthcheck", app.healthcheckHandler)
 
+	// The description of all the routes, for people and for client generators
+	router.HandlerFunc(http.MethodGet, "/v1/openapi.json", app.openAPIHandler)
+	router.HandlerFunc(http.MethodGet, "/v1/docs", app.docsHandler)
+
 	// -----
 	// Routes specific to quotes
 	router.HandlerFunc(http.MethodPost,
 		"/v1/quotes",
-		app.requireActivatedUser(app.requirePermission("quotes:write", app.createQuoteHandler)))
+		app.requireActivatedUser(app.requirePermission("quotes:write", app.validateRequest(app.createQuoteHandler))))
 
+	// The quote of the day is public, anyone can read it
 	router.HandlerFunc(http.MethodGet,
 		"/v1/quotes/:id",
-		app.requireActivatedUser(app.requirePermission("quotes:read", app.displayQuoteHandler)))
+		app.staticSegments("id", map[string]http.HandlerFunc{
+			"today":      app.validateRequest(app.todayQuoteHandler),
+			"random":     app.requireActivatedUser(app.requirePermission("quotes:read", app.validateRequest(app.randomQuotesHandler))),
+			"trash":      app.requireActivatedUser(app.requirePermission("quotes:write", app.validateRequest(app.listTrashHandler))),
+			"duplicates": app.requireActivatedUser(app.requirePermission("quotes:moderate", app.validateRequest(app.listDuplicatesHandler))),
+			"export":     app.requireActivatedUser(app.requirePermission("quotes:read", app.validateRequest(app.exportQuotesHandler))),
+			"stream":     app.requireActivatedUser(app.requirePermission("quotes:read", app.validateRequest(app.streamQuotesHandler))),
+		}, app.requireActivatedUser(app.requirePermission("quotes:read", app.displayQuoteHandler))))
 
 	router.HandlerFunc(http.MethodPatch,
 		"/v1/quotes/:id",
-		app.requireActivatedUser(app.requirePermission("quotes:write", app.updateQuoteHandler)))
+		app.requireActivatedUser(app.requirePermission("quotes:write", app.validateRequest(app.updateQuoteHandler))))
 
 	router.HandlerFunc(http.MethodDelete,
 		"/v1/quotes/:id",
@@ -37,17 +49,143 @@ func (app *application) routes() http.Handler {
 
 	router.HandlerFunc(http.MethodGet,
 		"/v1/quotes",
-		app.requireActivatedUser(app.requirePermission("quotes:read", app.listQuotesHandler)))
+		app.requireActivatedUser(app.requirePermission("quotes:read", app.validateRequest(app.listQuotesHandler))))
+
+	// POST only has fixed segments at /v1/quotes/:id, every other id is
+	// the wrong method
+	router.HandlerFunc(http.MethodPost,
+		"/v1/quotes/:id",
+		app.staticSegments("id", map[string]http.HandlerFunc{
+			"import": app.requireActivatedUser(app.requirePermission("quotes:write", app.validateRequest(app.importQuotesHandler))),
+		}, app.methodNotAllowedResponse))
+
+	router.HandlerFunc(http.MethodPost,
+		"/v1/quotes/:id/restore",
+		app.requireActivatedUser(app.requirePermission("quotes:write", app.restoreQuoteHandler)))
+
+	router.HandlerFunc(http.MethodDelete,
+		"/v1/quotes/:id/purge",
+		app.requireActivatedUser(app.requirePermission("quotes:moderate", app.purgeQuoteHandler)))
+
+	router.HandlerFunc(http.MethodGet,
+		"/v1/quotes/:id/revisions",
+		app.requireActivatedUser(app.requirePermission("quotes:read", app.validateRequest(app.listRevisionsHandler))))
+
+	router.HandlerFunc(http.MethodGet,
+		"/v1/quotes/:id/revisions/:version",
+		app.requireActivatedUser(app.requirePermission("quotes:read", app.displayRevisionHandler)))
+
+	router.HandlerFunc(http.MethodPost,
+		"/v1/quotes/:id/revisions/:version/revert",
+		app.requireActivatedUser(app.requirePermission("quotes:write", app.revertQuoteHandler)))
+
+	router.HandlerFunc(http.MethodGet,
+		"/v1/tags",
+		app.requireActivatedUser(app.requirePermission("quotes:read", app.listTagsHandler)))
+
+	router.HandlerFunc(http.MethodGet,
+		"/v1/search",
+		app.requireActivatedUser(app.requirePermission("quotes:read", app.validateRequest(app.searchHandler))))
+	// -----
+	// Feeds are public, just like the quote of the day, since feed
+	// readers can't log in
+	router.HandlerFunc(http.MethodGet, "/v1/feeds/daily.rss", app.dailyRSSHandler)
+	router.HandlerFunc(http.MethodGet, "/v1/feeds/daily.atom", app.dailyAtomHandler)
+	router.HandlerFunc(http.MethodGet, "/v1/feeds/latest.atom", app.latestAtomHandler)
+
+	// -----
+	// Routes specific to authors
+	router.HandlerFunc(http.MethodPost,
+		"/v1/authors",
+		app.requireActivatedUser(app.requirePermission("quotes:write", app.validateRequest(app.createAuthorHandler))))
+
+	router.HandlerFunc(http.MethodGet,
+		"/v1/authors",
+		app.requireActivatedUser(app.requirePermission("quotes:read", app.validateRequest(app.listAuthorsHandler))))
+
+	router.HandlerFunc(http.MethodGet,
+		"/v1/authors/:id",
+		app.requireActivatedUser(app.requirePermission("quotes:read",
+			app.staticSegments("id", map[string]http.HandlerFunc{
+				"suggest": app.validateRequest(app.suggestAuthorsHandler),
+			}, app.displayAuthorHandler))))
+
+	router.HandlerFunc(http.MethodPatch,
+		"/v1/authors/:id",
+		app.requireActivatedUser(app.requirePermission("quotes:write", app.validateRequest(app.updateAuthorHandler))))
+
+	router.HandlerFunc(http.MethodDelete,
+		"/v1/authors/:id",
+		app.requireActivatedUser(app.requirePermission("quotes:write", app.deleteAuthorHandler)))
+
+	router.HandlerFunc(http.MethodGet,
+		"/v1/authors/:id/quotes",
+		app.requireActivatedUser(app.requirePermission("quotes:read", app.validateRequest(app.listAuthorQuotesHandler))))
+
+	// -----
+	// Routes for the featured quote schedule (editors only)
+	router.HandlerFunc(http.MethodPost,
+		"/v1/schedule",
+		app.requireActivatedUser(app.requirePermission("quotes:write", app.validateRequest(app.createScheduleHandler))))
+
+	router.HandlerFunc(http.MethodGet,
+		"/v1/schedule",
+		app.requireActivatedUser(app.requirePermission("quotes:write", app.validateRequest(app.listScheduleHandler))))
+
+	router.HandlerFunc(http.MethodPatch,
+		"/v1/schedule/:date",
+		app.requireActivatedUser(app.requirePermission("quotes:write", app.validateRequest(app.updateScheduleHandler))))
+
+	router.HandlerFunc(http.MethodDelete,
+		"/v1/schedule/:date",
+		app.requireActivatedUser(app.requirePermission("quotes:write", app.deleteScheduleHandler)))
+	// -----
+
+	// One request for anything the client needs. The resolvers check the
+	// permissions just like the routes above
+	router.HandlerFunc(http.MethodPost,
+		"/v1/graphql",
+		app.requireActivatedUser(app.validateRequest(app.graphqlHandler)))
+
 	// -----
+	// Routes for the webhooks of the user
+	router.HandlerFunc(http.MethodPost,
+		"/v1/webhooks",
+		app.requireActivatedUser(app.requirePermission("webhooks:manage", app.validateRequest(app.createWebhookHandler))))
 
-	router.HandlerFunc(http.MethodPost, "/v1/users", app.registerUserHandler)
+	router.HandlerFunc(http.MethodGet,
+		"/v1/webhooks",
+		app.requireActivatedUser(app.requirePermission("webhooks:manage", app.validateRequest(app.listWebhooksHandler))))
+
+	router.HandlerFunc(http.MethodGet,
+		"/v1/webhooks/:id",
+		app.requireActivatedUser(app.requirePermission("webhooks:manage", app.displayWebhookHandler)))
+
+	router.HandlerFunc(http.MethodPatch,
+		"/v1/webhooks/:id",
+		app.requireActivatedUser(app.requirePermission("webhooks:manage", app.validateRequest(app.updateWebhookHandler))))
+
+	router.HandlerFunc(http.MethodDelete,
+		"/v1/webhooks/:id",
+		app.requireActivatedUser(app.requirePermission("webhooks:manage", app.deleteWebhookHandler)))
+
+	router.HandlerFunc(http.MethodGet,
+		"/v1/webhooks/:id/deliveries",
+		app.requireActivatedUser(app.requirePermission("webhooks:manage", app.validateRequest(app.listDeliveriesHandler))))
+
+	router.HandlerFunc(http.MethodPost,
+		"/v1/webhooks/:id/deliveries/:delivery_id/replay",
+		app.requireActivatedUser(app.requirePermission("webhooks:manage", app.replayDeliveryHandler)))
+	// -----
+
+	router.HandlerFunc(http.MethodPost, "/v1/users", app.validateRequest(app.registerUserHandler))
 
 	// We use PUT instead of POST because PUT is idempotent
 	// and appropriate for this endpoint.  The activation
 	// should only happens once, also we are not creating a resource
-	router.HandlerFunc(http.MethodPut, "/v1/users/activated", app.activateUserHandler)
+	router.HandlerFunc(http.MethodPut, "/v1/users/activated", app.validateRequest(app.activateUserHandler))
 
-	router.HandlerFunc(http.MethodPost, "/v1/tokens/authentication", app.createAuthenticationTokenHandler)
+	router.HandlerFunc(http.MethodPost, "/v1/tokens/authentication", app.validateRequest(app.createAuthenticationTokenHandler))
 
 	// Request sent first to recoverPanic()
 	// then sent to enableCORS()
@@ -61,3 +199,22 @@ func (app *application) routes() http.Handler {
 
 	return app.metrics(app.recoverPanic(app.enableCORS(app.rateLimit((app.authenticate(router))))))
 }
+
+// httprouter panics if a fixed path segment such as "today" is registered
+// in the same position as a named parameter such as ":id". So we register
+// the parameter route only, and when the parameter holds one of the fixed
+// segments we send the request to that segment's handler instead
+func (app *application) staticSegments(param string,
+	static map[string]http.HandlerFunc,
+	next http.HandlerFunc) http.HandlerFunc {
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		params := httprouter.ParamsFromContext(r.Context())
+		handler, found := static[params.ByName(param)]
+		if found {
+			handler.ServeHTTP(w, r)
+			return
+		}
+		next.ServeHTTP(w, r)
+	}
+}
@@ -0,0 +1,289 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/2016114132/qod/internal/data"
+	"github.com/2016114132/qod/internal/validator"
+)
+
+func (a *application) createScheduleHandler(w http.ResponseWriter,
+	r *http.Request) {
+	// The client tells us which quote to show on which date
+	var incomingData struct {
+		QuoteID int64  `json:"quote_id"`
+		Date    string `json:"date"`
+	}
+	err := a.readJSON(w, r, &incomingData)
+	if err != nil {
+		a.badRequestResponse(w, r, err)
+		return
+	}
+
+	scheduled := &data.ScheduledQuote{
+		QuoteID: incomingData.QuoteID,
+		Date:    incomingData.Date,
+	}
+
+	v := validator.New()
+	data.ValidateScheduledQuote(v, scheduled)
+	if !v.IsEmpty() {
+		a.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	// We can only schedule quotes that (still) exist
+	quote, ok := a.readScheduleQuote(w, r, v, scheduled.QuoteID)
+	if !ok {
+		return
+	}
+
+	err = a.scheduleModel.Insert(scheduled)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrDuplicateDate):
+			v.AddError("date", "a quote is already scheduled for this date")
+			a.failedValidationResponse(w, r, v.Errors)
+		default:
+			a.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+	a.queueWebhooks(r, data.WebhookPayload{
+		Event:   data.QuoteFeatured,
+		QuoteID: quote.ID,
+		Quote:   quote,
+		Date:    scheduled.Date,
+	})
+
+	headers := make(http.Header)
+	headers.Set("Location", fmt.Sprintf("/v1/schedule/%s", scheduled.Date))
+
+	data := envelope{
+		"scheduled_quote": scheduled,
+	}
+	err = a.render(w, r, http.StatusCreated, data, headers)
+	if err != nil {
+		a.serverErrorResponse(w, r, err)
+	}
+}
+
+func (a *application) listScheduleHandler(w http.ResponseWriter,
+	r *http.Request) {
+	var queryParametersData struct {
+		From string
+		To   string
+		data.Filters
+	}
+	queryParameters := r.URL.Query()
+
+	v := validator.New()
+
+	// Both ends of the date range are optional
+	queryParametersData.From = a.getSingleQueryParameter(queryParameters, "from", "")
+	if queryParametersData.From != "" {
+		_, err := time.Parse("2006-01-02", queryParametersData.From)
+		v.Check(err == nil, "from", "must be a date in the format YYYY-MM-DD")
+	}
+	queryParametersData.To = a.getSingleQueryParameter(queryParameters, "to", "")
+	if queryParametersData.To != "" {
+		_, err := time.Parse("2006-01-02", queryParametersData.To)
+		v.Check(err == nil, "to", "must be a date in the format YYYY-MM-DD")
+	}
+
+	queryParametersData.Filters.Page = a.getSingleIntegerParameter(
+		queryParameters, "page", 1, v)
+	queryParametersData.Filters.PageSize = a.getSingleIntegerParameter(
+		queryParameters, "page_size", 10, v)
+	queryParametersData.Filters.Sort = a.getSingleQueryParameter(
+		queryParameters, "sort", "date")
+	queryParametersData.Filters.SortSafeList = []string{"date", "-date"}
+
+	data.ValidateFilters(v, queryParametersData.Filters)
+	if !v.IsEmpty() {
+		a.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	schedule, metadata, err := a.scheduleModel.GetAll(
+		queryParametersData.From,
+		queryParametersData.To,
+		queryParametersData.Filters,
+	)
+	if err != nil {
+		a.serverErrorResponse(w, r, err)
+		return
+	}
+
+	data := envelope{
+		"schedule":  schedule,
+		"@metadata": metadata,
+	}
+	err = a.render(w, r, http.StatusOK, data, nil)
+	if err != nil {
+		a.serverErrorResponse(w, r, err)
+	}
+}
+
+// Move the quote scheduled for the date in the URL to another date
+// and/or replace the quote scheduled for that date
+func (a *application) updateScheduleHandler(w http.ResponseWriter,
+	r *http.Request) {
+	date, err := a.readDateParam(r)
+	if err != nil {
+		a.notFoundResponse(w, r)
+		return
+	}
+	scheduled, err := a.scheduleModel.GetForDate(date)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			a.notFoundResponse(w, r)
+		default:
+			a.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	var incomingData struct {
+		QuoteID *int64  `json:"quote_id"`
+		Date    *string `json:"date"`
+	}
+	err = a.readJSON(w, r, &incomingData)
+	if err != nil {
+		a.badRequestResponse(w, r, err)
+		return
+	}
+	if incomingData.Date != nil {
+		scheduled.Date = *incomingData.Date
+	}
+	if incomingData.QuoteID != nil {
+		scheduled.QuoteID = *incomingData.QuoteID
+	}
+
+	v := validator.New()
+	data.ValidateScheduledQuote(v, scheduled)
+	if !v.IsEmpty() {
+		a.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+	_, ok := a.readScheduleQuote(w, r, v, scheduled.QuoteID)
+	if !ok {
+		return
+	}
+
+	err = a.scheduleModel.Update(scheduled)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrDuplicateDate):
+			v.AddError("date", "a quote is already scheduled for this date")
+			a.failedValidationResponse(w, r, v.Errors)
+		case errors.Is(err, data.ErrEditConflict):
+			a.editConflictResponse(w, r)
+		default:
+			a.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+	// the quote might have been swapped so we reload the entry
+	scheduled, err = a.scheduleModel.GetForDate(scheduled.Date)
+	if err != nil {
+		a.serverErrorResponse(w, r, err)
+		return
+	}
+	a.queueWebhooks(r, data.WebhookPayload{
+		Event:   data.QuoteFeatured,
+		QuoteID: scheduled.QuoteID,
+		Quote:   scheduled.Quote,
+		Date:    scheduled.Date,
+	})
+
+	data := envelope{
+		"scheduled_quote": scheduled,
+	}
+	err = a.render(w, r, http.StatusOK, data, nil)
+	if err != nil {
+		a.serverErrorResponse(w, r, err)
+	}
+}
+
+// Clear a date so that the automatic selection is used for that day
+func (a *application) deleteScheduleHandler(w http.ResponseWriter,
+	r *http.Request) {
+	date, err := a.readDateParam(r)
+	if err != nil {
+		a.notFoundResponse(w, r)
+		return
+	}
+	err = a.scheduleModel.Delete(date)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			a.notFoundResponse(w, r)
+		default:
+			a.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	data := envelope{
+		"message": "scheduled quote successfully cleared",
+	}
+	err = a.render(w, r, http.StatusOK, data, nil)
+	if err != nil {
+		a.serverErrorResponse(w, r, err)
+	}
+}
+
+// Get the quote we are about to schedule, it must (still) exist. If it
+// does not, or something went wrong, the response is sent and we
+// return false
+func (a *application) readScheduleQuote(w http.ResponseWriter,
+	r *http.Request,
+	v *validator.Validator,
+	quoteID int64) (*data.Quote, bool) {
+
+	quote, err := a.quoteModel.Get(quoteID)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			v.AddError("quote_id", "must refer to an existing quote")
+			a.failedValidationResponse(w, r, v.Errors)
+		default:
+			a.serverErrorResponse(w, r, err)
+		}
+		return nil, false
+	}
+
+	return quote, true
+}
+
+// Once an hour, save the automatic quote of today (UTC) in the schedule,
+// unless an editor picked one. Requests only ever read the picks, this
+// is what keeps today's from changing when quotes are added or removed.
+// It stops when done is closed
+func (a *application) pickDailyQuotes(done <-chan struct{}) {
+	a.wg.Add(1)
+	go func() {
+		defer a.wg.Done()
+
+		ticker := time.NewTicker(time.Hour)
+		defer ticker.Stop()
+
+		for {
+			err := a.scheduleModel.Pick(time.Now().UTC())
+			if err != nil {
+				a.logger.Error(err.Error())
+			}
+
+			select {
+			case <-ticker.C:
+			case <-done:
+				return
+			}
+		}
+	}()
+}
@@ -0,0 +1,49 @@
+package main
+
+import (
+	"net/http"
+
+	"github.com/2016114132/qod/internal/data"
+	"github.com/2016114132/qod/internal/validator"
+)
+
+// Full text search over the quotes, ranked by relevance. Besides the
+// q parameter it takes lang (english by default), page and page_size
+func (a *application) searchHandler(w http.ResponseWriter,
+	r *http.Request) {
+	var filters data.Filters
+	queryParameters := r.URL.Query()
+
+	v := validator.New()
+
+	search := a.getSingleQueryParameter(queryParameters, "q", "")
+	lang := a.getSingleQueryParameter(queryParameters, "lang", "english")
+
+	filters.Page = a.getSingleIntegerParameter(queryParameters, "page", 1, v)
+	filters.PageSize = a.getSingleIntegerParameter(queryParameters, "page_size", 10, v)
+	// the results always come best match first
+	filters.Sort = "-rank"
+	filters.SortSafeList = []string{"-rank"}
+
+	data.ValidateSearch(v, search, lang)
+	data.ValidateFilters(v, filters)
+	if !v.IsEmpty() {
+		a.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	results, metadata, err := a.quoteModel.Search(search, lang, filters)
+	if err != nil {
+		a.serverErrorResponse(w, r, err)
+		return
+	}
+
+	data := envelope{
+		"results":   results,
+		"@metadata": metadata,
+	}
+	err = a.render(w, r, http.StatusOK, data, nil)
+	if err != nil {
+		a.serverErrorResponse(w, r, err)
+	}
+}
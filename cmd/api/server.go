@@ -24,6 +24,27 @@ func (app *application) serve() error {
 		ErrorLog:     slog.NewLogLogger(app.logger.Handler(), slog.LevelError),
 	}
 
+	// start our background jobs. Closing done tells them to stop
+	done := make(chan struct{})
+	app.purgeTrash(done)
+	app.pickDailyQuotes(done)
+	app.deliverWebhooks(done)
+	err := app.listenQuoteEvents(done)
+	if err != nil {
+		return err
+	}
+	// the quote streams never end on their own, Shutdown() would wait
+	// for them until it times out
+	srv.RegisterOnShutdown(app.quoteEvents.close)
+
+	// the gRPC server runs next to ours and stops with it
+	grpcSrv, err := app.serveGRPC()
+	if err != nil {
+		close(done)
+		app.wg.Wait()
+		return err
+	}
+
 	// create a channel to keep track of any errors during the shutdown process
 	shutdownError := make(chan error)
 	// create a goroutine that runs in the background listening
@@ -38,13 +59,21 @@ func (app *application) serve() error {
 		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
 		defer cancel()
 
+		// both servers wait for the requests in progress, at the same time
+		grpcStopped := make(chan struct{})
+		go func() {
+			stopGRPCServer(ctx, grpcSrv)
+			close(grpcStopped)
+		}()
 		// we will only write to the error channel if there is an error
 		err := srv.Shutdown(ctx)
+		<-grpcStopped
 		if err != nil {
 			shutdownError <- err
 		}
 		// Wait for background tasks to complete
 		app.logger.Info("completing background tasks", "address", srv.Addr)
+		close(done)
 		app.wg.Wait()
 		shutdownError <- nil // successful shutdown
 
@@ -57,8 +86,13 @@ func (app *application) serve() error {
 	// something went wrong during shutdown if we don't get ErrServerClosed()
 	// this only happens when we issue the shutdown command from our goroutine
 	// otherwise our server keeps running as normal as it should.
-	err := srv.ListenAndServe()
+	err = srv.ListenAndServe()
 	if !errors.Is(err, http.ErrServerClosed) {
+		// we never got going (the port is in use...), so we stop what
+		// we already started
+		grpcSrv.Stop()
+		close(done)
+		app.wg.Wait()
 		return err
 	}
 
@@ -0,0 +1,285 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/2016114132/qod/internal/data"
+	"github.com/2016114132/qod/internal/validator"
+	"github.com/lib/pq"
+)
+
+const (
+	// how many events we read from the database at a time
+	streamBatchSize = 100
+	// a comment is sent this often so that proxies keep the stream open
+	streamKeepAlive = 15 * time.Second
+	// how long clients can be away and still resume the stream
+	quoteEventRetention = 7 * 24 * time.Hour
+)
+
+// Wakes up the streams when there are new quote events. The events
+// themselves are read from the database, so a stream that is woken up
+// once for several events still gets all of them
+type quoteEventHub struct {
+	mu          sync.Mutex
+	subscribers map[chan struct{}]struct{}
+	closed      bool
+}
+
+func newQuoteEventHub() *quoteEventHub {
+	return &quoteEventHub{
+		subscribers: make(map[chan struct{}]struct{}),
+	}
+}
+
+// Get a channel that receives a value when there are new events. It is
+// closed when the hub is. The caller must unsubscribe() when it is done
+func (h *quoteEventHub) subscribe() chan struct{} {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	ch := make(chan struct{}, 1)
+	if h.closed {
+		close(ch)
+		return ch
+	}
+	h.subscribers[ch] = struct{}{}
+	return ch
+}
+
+func (h *quoteEventHub) unsubscribe(ch chan struct{}) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if _, ok := h.subscribers[ch]; ok {
+		delete(h.subscribers, ch)
+		close(ch)
+	}
+}
+
+// Wake up every subscriber. A subscriber that has not caught up with the
+// last wake up yet is not woken up twice
+func (h *quoteEventHub) notify() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	for ch := range h.subscribers {
+		select {
+		case ch <- struct{}{}:
+		default:
+		}
+	}
+}
+
+// End every stream. We do it when the server shuts down, since the
+// streams would otherwise keep it waiting
+func (h *quoteEventHub) close() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	for ch := range h.subscribers {
+		delete(h.subscribers, ch)
+		close(ch)
+	}
+	h.closed = true
+}
+
+// Listen for the quote events that the database announces and wake up
+// the streams. Old events are removed every hour
+func (a *application) listenQuoteEvents(done <-chan struct{}) error {
+	listener := pq.NewListener(a.config.db.dsn, 10*time.Second, time.Minute,
+		func(event pq.ListenerEventType, err error) {
+			if err != nil {
+				a.logger.Error(err.Error())
+			}
+		})
+	err := listener.Listen(data.QuoteEventsChannel)
+	if err != nil {
+		listener.Close()
+		return err
+	}
+
+	a.wg.Add(1)
+	go func() {
+		defer a.wg.Done()
+		defer listener.Close()
+
+		prune := time.NewTicker(time.Hour)
+		defer prune.Stop()
+		ping := time.NewTicker(90 * time.Second)
+		defer ping.Stop()
+
+		for {
+			select {
+			// a nil notification means that the connection was lost and
+			// made again. We may have missed events so we wake up anyway
+			case <-listener.Notify:
+				a.quoteEvents.notify()
+			case <-ping.C:
+				go listener.Ping()
+			case <-prune.C:
+				deleted, err := a.quoteEventModel.DeleteBefore(
+					time.Now().Add(-quoteEventRetention))
+				if err != nil {
+					a.logger.Error(err.Error())
+				} else if deleted > 0 {
+					a.logger.Info("removed old quote events", "count", deleted)
+				}
+			case <-done:
+				return
+			}
+		}
+	}()
+
+	return nil
+}
+
+// Stream the quote events as server-sent events. A client that lost the
+// stream sends the id of the last event it got in the Last-Event-ID
+// header (or the last_event_id query parameter) and gets every event
+// since then. Otherwise the stream starts with the next event
+func (a *application) streamQuotesHandler(w http.ResponseWriter, r *http.Request) {
+	lastEventID := r.Header.Get("Last-Event-ID")
+	if lastEventID == "" {
+		lastEventID = r.URL.Query().Get("last_event_id")
+	}
+
+	var lastID int64
+	if lastEventID != "" {
+		v := validator.New()
+		id, err := strconv.ParseInt(lastEventID, 10, 64)
+		v.Check(err == nil && id >= 0, "last_event_id", "must be a valid event id")
+		if !v.IsEmpty() {
+			a.failedValidationResponse(w, r, v.Errors)
+			return
+		}
+		lastID = id
+	}
+
+	// subscribe before we look at the events so that we don't miss any
+	wakeUp := a.quoteEvents.subscribe()
+	defer a.quoteEvents.unsubscribe(wakeUp)
+
+	if lastEventID == "" {
+		id, err := a.quoteEventModel.LatestID()
+		if err != nil {
+			a.serverErrorResponse(w, r, err)
+			return
+		}
+		lastID = id
+	}
+
+	// the stream stays open for as long as the client wants it
+	rc := http.NewResponseController(w)
+	err := rc.SetWriteDeadline(time.Time{})
+	if err != nil && !errors.Is(err, http.ErrNotSupported) {
+		a.serverErrorResponse(w, r, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("X-Accel-Buffering", "no") // nginx must not buffer it
+	w.WriteHeader(http.StatusOK)
+
+	// how long a client waits before it connects again
+	_, err = fmt.Fprint(w, "retry: 5000\n\n")
+	if err != nil {
+		return
+	}
+
+	keepAlive := time.NewTicker(streamKeepAlive)
+	defer keepAlive.Stop()
+
+	for {
+		lastID, err = a.sendQuoteEvents(w, lastID)
+		if err != nil {
+			// a client that went away is not an error
+			if r.Context().Err() == nil {
+				a.logger.Error(err.Error())
+			}
+			return
+		}
+		err = rc.Flush()
+		if err != nil {
+			return
+		}
+
+		select {
+		case <-r.Context().Done():
+			return
+		case _, ok := <-wakeUp:
+			if !ok {
+				return
+			}
+		// the events of a transaction that committed while an older one
+		// was still going on are only sent once that one is done, so we
+		// look again now and then even without a notification
+		case <-keepAlive.C:
+			_, err = fmt.Fprint(w, ": keep-alive\n\n")
+			if err != nil {
+				return
+			}
+		}
+	}
+}
+
+// Write the events after lastID and return the id of the last one written
+func (a *application) sendQuoteEvents(w http.ResponseWriter, lastID int64) (int64, error) {
+	for {
+		events, err := a.quoteEventModel.GetAfter(lastID, streamBatchSize)
+		if err != nil {
+			return lastID, err
+		}
+
+		// the quotes of the whole batch in one query
+		ids := []int64{}
+		for _, event := range events {
+			if event.Type != data.QuoteDeleted {
+				ids = append(ids, event.QuoteID)
+			}
+		}
+		quotes := map[int64]*data.Quote{}
+		if len(ids) > 0 {
+			quotes, err = a.quoteModel.GetMany(ids)
+			if err != nil {
+				return lastID, err
+			}
+		}
+
+		for _, event := range events {
+			// a quote that is missing was deleted since, the event that
+			// says so comes later
+			if event.Type != data.QuoteDeleted {
+				event.Quote = quotes[event.QuoteID]
+			}
+
+			err = writeServerSentEvent(w, event)
+			if err != nil {
+				return lastID, err
+			}
+			lastID = event.ID
+		}
+
+		if len(events) < streamBatchSize {
+			return lastID, nil
+		}
+	}
+}
+
+// An event in the text/event-stream format. The JSON has no new lines,
+// so it fits on a single data line
+func writeServerSentEvent(w http.ResponseWriter, event *data.QuoteEvent) error {
+	js, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(w, "id: %d\nevent: %s\ndata: %s\n\n", event.ID, event.Type, js)
+	return err
+}
@@ -0,0 +1,78 @@
+// Filename: cmd/api/stream_internal_test.go
+
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/2016114132/qod/internal/data"
+)
+
+func TestQuoteEventHub(t *testing.T) {
+	hub := newQuoteEventHub()
+	first := hub.subscribe()
+	second := hub.subscribe()
+
+	// several events before the subscriber looks wake it up once
+	hub.notify()
+	hub.notify()
+	for name, ch := range map[string]chan struct{}{"first": first, "second": second} {
+		select {
+		case <-ch:
+		default:
+			t.Errorf("%s: expected: a wake up, got: none", name)
+		}
+		select {
+		case <-ch:
+			t.Errorf("%s: expected: one wake up, got: two", name)
+		default:
+		}
+	}
+
+	hub.unsubscribe(first)
+	hub.close()
+	select {
+	case _, ok := <-second:
+		if ok {
+			t.Errorf("expected: a closed channel, got: a wake up")
+		}
+	case <-time.After(time.Second):
+		t.Errorf("expected: a closed channel, got: an open one")
+	}
+
+	// nobody can subscribe to a closed hub
+	_, ok := <-hub.subscribe()
+	if ok {
+		t.Errorf("expected: a closed channel, got: a wake up")
+	}
+}
+
+func TestStreamThroughMetricsWriter(t *testing.T) {
+	rr := httptest.NewRecorder()
+	mw := newMetricsResponseWriter(rr)
+
+	err := writeServerSentEvent(mw, &data.QuoteEvent{
+		ID:      7,
+		QuoteID: 3,
+		Type:    data.QuoteDeleted,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = http.NewResponseController(mw).Flush()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !rr.Flushed {
+		t.Errorf("expected: a flushed response, got: none")
+	}
+	want := "id: 7\nevent: quote.deleted\n" +
+		`data: {"id":7,"quote_id":3,"type":"quote.deleted","created_at":"0001-01-01T00:00:00Z"}` + "\n\n"
+	if rr.Body.String() != want {
+		t.Errorf("expected: %q, got: %q", want, rr.Body.String())
+	}
+}
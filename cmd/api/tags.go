@@ -0,0 +1,24 @@
+package main
+
+import (
+	"net/http"
+)
+
+// List the tags that are in use along with how many quotes use them
+func (a *application) listTagsHandler(w http.ResponseWriter,
+	r *http.Request) {
+
+	tags, err := a.tagModel.GetAll()
+	if err != nil {
+		a.serverErrorResponse(w, r, err)
+		return
+	}
+
+	data := envelope{
+		"tags": tags,
+	}
+	err = a.render(w, r, http.StatusOK, data, nil)
+	if err != nil {
+		a.serverErrorResponse(w, r, err)
+	}
+}
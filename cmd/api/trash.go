@@ -0,0 +1,171 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/2016114132/qod/internal/data"
+	"github.com/2016114132/qod/internal/validator"
+)
+
+// List the quotes in the trash. Moderators see every deleted quote,
+// everyone else only sees the quotes they created
+func (a *application) listTrashHandler(w http.ResponseWriter,
+	r *http.Request) {
+	user := a.contextGetUser(r)
+	permissions, err := a.permissionModel.GetAllForUser(user.ID)
+	if err != nil {
+		a.serverErrorResponse(w, r, err)
+		return
+	}
+	createdBy := user.ID
+	if permissions.Include("quotes:moderate") {
+		createdBy = 0
+	}
+
+	var filters data.Filters
+	queryParameters := r.URL.Query()
+
+	v := validator.New()
+
+	filters.Page = a.getSingleIntegerParameter(queryParameters, "page", 1, v)
+	filters.PageSize = a.getSingleIntegerParameter(queryParameters, "page_size", 10, v)
+	filters.Sort = a.getSingleQueryParameter(queryParameters, "sort", "-deleted_at")
+	filters.SortSafeList = []string{"id", "deleted_at", "-id", "-deleted_at"}
+
+	data.ValidateFilters(v, filters)
+	if !v.IsEmpty() {
+		a.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	quotes, metadata, err := a.quoteModel.GetAllDeleted(createdBy, filters)
+	if err != nil {
+		a.serverErrorResponse(w, r, err)
+		return
+	}
+
+	data := envelope{
+		"quotes":    quotes,
+		"@metadata": metadata,
+	}
+	err = a.render(w, r, http.StatusOK, data, nil)
+	if err != nil {
+		a.serverErrorResponse(w, r, err)
+	}
+}
+
+// Take a quote out of the trash. Just like editing, only the owner of
+// the quote or a moderator may do this
+func (a *application) restoreQuoteHandler(w http.ResponseWriter,
+	r *http.Request) {
+	id, err := a.readIDParam(r)
+	if err != nil {
+		a.notFoundResponse(w, r)
+		return
+	}
+	quote, err := a.quoteModel.GetDeleted(id)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			a.notFoundResponse(w, r)
+		default:
+			a.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	editor, err := a.quoteEditor(a.contextGetUser(r))
+	if err != nil {
+		a.serverErrorResponse(w, r, err)
+		return
+	}
+	err = a.quoteModel.Restore(quote, editor)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			a.notFoundResponse(w, r)
+		case errors.Is(err, data.ErrNotPermitted):
+			a.notPermittedResponse(w, r)
+		default:
+			a.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+	// for the receivers a quote back from the trash is a new quote, just
+	// like in the quote stream
+	a.queueWebhooks(r, data.WebhookPayload{
+		Event:   data.QuoteCreated,
+		QuoteID: quote.ID,
+		Quote:   quote,
+	})
+
+	data := envelope{
+		"quote": quote,
+	}
+	err = a.render(w, r, http.StatusOK, data, nil)
+	if err != nil {
+		a.serverErrorResponse(w, r, err)
+	}
+}
+
+// Permanently delete a quote that is in the trash (moderators only)
+func (a *application) purgeQuoteHandler(w http.ResponseWriter,
+	r *http.Request) {
+	id, err := a.readIDParam(r)
+	if err != nil {
+		a.notFoundResponse(w, r)
+		return
+	}
+	err = a.quoteModel.Purge(id)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			a.notFoundResponse(w, r)
+		default:
+			a.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	data := envelope{
+		"message": "quote successfully purged",
+	}
+	err = a.render(w, r, http.StatusOK, data, nil)
+	if err != nil {
+		a.serverErrorResponse(w, r, err)
+	}
+}
+
+// Once an hour, permanently delete the quotes that have been in the
+// trash for longer than the retention window. It stops when done is closed
+func (a *application) purgeTrash(done <-chan struct{}) {
+	if a.config.trash.retention <= 0 {
+		return
+	}
+
+	a.wg.Add(1)
+	go func() {
+		defer a.wg.Done()
+
+		ticker := time.NewTicker(time.Hour)
+		defer ticker.Stop()
+
+		for {
+			purged, err := a.quoteModel.PurgeDeletedBefore(
+				time.Now().Add(-a.config.trash.retention))
+			if err != nil {
+				a.logger.Error(err.Error())
+			} else if purged > 0 {
+				a.logger.Info("purged quotes from the trash", "count", purged)
+			}
+
+			select {
+			case <-ticker.C:
+			case <-done:
+				return
+			}
+		}
+	}()
+}
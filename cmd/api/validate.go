@@ -0,0 +1,268 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/getkin/kin-openapi/openapi3"
+	"github.com/getkin/kin-openapi/openapi3filter"
+	"github.com/getkin/kin-openapi/routers"
+	"github.com/getkin/kin-openapi/routers/gorillamux"
+)
+
+// The operations whose body isn't checked against the OpenAPI document.
+// The import also takes CSV and NDJSON, and its handler checks it row
+// by row
+var unvalidatedBodies = map[string]bool{
+	"importQuotes": true,
+}
+
+// Find the operation of a request in the OpenAPI document. The document
+// is embedded and the tests load it, so it can't really fail
+func newOpenAPIRouter() routers.Router {
+	spec, err := openapi3.NewLoader().LoadFromData(openAPISpec)
+	if err != nil {
+		panic(err)
+	}
+	router, err := gorillamux.NewRouter(spec)
+	if err != nil {
+		panic(err)
+	}
+	return router
+}
+
+// Check the query parameters and the JSON body of a request against the
+// OpenAPI document before the handler gets it, so what the docs say is
+// what we accept. It goes right around the handler in routes(), after
+// the checks of the user and the permissions: who may not use a route
+// doesn't get to find out what it takes. A bad id in the path is left
+// to the handler (404) and so is a body that isn't JSON at all
+// (readJSON() sends the 400)
+func (a *application) validateRequest(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		validationErrors := a.checkRequest(w, r)
+		if len(validationErrors) > 0 {
+			a.failedValidationResponse(w, r, validationErrors)
+			return
+		}
+		next.ServeHTTP(w, r)
+	}
+}
+
+// The same checks for the arguments of a gRPC or GraphQL call, given as
+// the query parameters of the HTTP route that does the same thing
+func (a *application) checkQueryParameters(path string, queryParameters url.Values) map[string]string {
+	r, err := http.NewRequest(http.MethodGet, path+"?"+queryParameters.Encode(), nil)
+	if err != nil {
+		panic(err) // the path is one of ours
+	}
+	return a.checkRequest(nil, r)
+}
+
+// What is wrong with the request according to the OpenAPI document. The
+// body is read, so r gets a copy of it for the handler
+func (a *application) checkRequest(w http.ResponseWriter, r *http.Request) map[string]string {
+	route, pathParams, err := a.openAPIRouter.FindRoute(r)
+	if err != nil {
+		return nil
+	}
+
+	// readJSON() reads the body as JSON whatever the Content-Type,
+	// so that is how we check it too
+	checkBody := !unvalidatedBodies[route.Operation.OperationID]
+	validationRequest := r.Clone(r.Context())
+	if checkBody && r.Body != nil {
+		validationRequest.Body = http.MaxBytesReader(w, r.Body, jsonMaxBytes)
+		validationRequest.Header.Set("Content-Type", "application/json")
+	}
+
+	err = openapi3filter.ValidateRequest(r.Context(), &openapi3filter.RequestValidationInput{
+		Request:    validationRequest,
+		PathParams: pathParams,
+		Route:      route,
+		Options: &openapi3filter.Options{
+			ExcludeRequestBody:  !checkBody,
+			MultiError:          true,
+			SkipSettingDefaults: true, // the handlers have their own
+			// authenticate() and requirePermission() deal with the tokens
+			AuthenticationFunc: openapi3filter.NoopAuthenticationFunc,
+		},
+	})
+	r.Body = validationRequest.Body
+
+	return requestValidationErrors(err)
+}
+
+// Turn what openapi3filter found into the field: message map of
+// failedValidationResponse(). The first message of a field wins, just
+// like with the validator
+func requestValidationErrors(err error) map[string]string {
+	validationErrors := make(map[string]string)
+	if err == nil {
+		return validationErrors
+	}
+
+	var found openapi3.MultiError
+	if !errors.As(err, &found) {
+		found = openapi3.MultiError{err}
+	}
+
+	addError := func(key, message string) {
+		if _, exists := validationErrors[key]; !exists {
+			validationErrors[key] = message
+		}
+	}
+
+	for _, err := range found {
+		var requestError *openapi3filter.RequestError
+		if !errors.As(err, &requestError) {
+			continue
+		}
+
+		switch {
+		case requestError.Parameter != nil:
+			parameter := requestError.Parameter
+			// the handlers send a 404 for an id that can't exist
+			if parameter.In == openapi3.ParameterInPath {
+				continue
+			}
+			var parseError *openapi3filter.ParseError
+			switch {
+			// an empty value is the same as a missing one
+			case errors.Is(requestError.Err, openapi3filter.ErrInvalidEmptyValue):
+			case errors.Is(requestError.Err, openapi3filter.ErrInvalidRequired):
+				addError(parameter.Name, "must be provided")
+			case errors.As(requestError.Err, &parseError):
+				addError(parameter.Name, typeErrorMessage(parameter.Schema.Value))
+			default:
+				for _, schemaError := range schemaErrors(requestError.Err) {
+					addError(parameter.Name, schemaErrorMessage(schemaError))
+				}
+			}
+
+		// only the shape of the body, a body that is missing, too large
+		// or badly-formed is for readJSON()
+		case requestError.RequestBody != nil:
+			for _, schemaError := range schemaErrors(requestError.Err) {
+				// readJSON() has its own message for an unknown key, and
+				// a null is the same as a missing value once decoded
+				if schemaError.SchemaField == "properties" ||
+					schemaError.SchemaField == "nullable" {
+					continue
+				}
+				addError(schemaErrorKey(schemaError), schemaErrorMessage(schemaError))
+			}
+		}
+	}
+
+	return validationErrors
+}
+
+// The schema errors in err, a MultiError can hold more MultiErrors
+func schemaErrors(err error) []*openapi3.SchemaError {
+	switch err := err.(type) {
+	case *openapi3.SchemaError:
+		return []*openapi3.SchemaError{err}
+	case openapi3.MultiError:
+		var found []*openapi3.SchemaError
+		for _, e := range err {
+			found = append(found, schemaErrors(e)...)
+		}
+		return found
+	}
+	return nil
+}
+
+// The field of the body as the handlers name it: "tags" for every tag,
+// "author" for the author. The body itself when it has the wrong shape
+func schemaErrorKey(schemaError *openapi3.SchemaError) string {
+	var keys []string
+	for _, key := range schemaError.JSONPointer() {
+		if _, err := strconv.Atoi(key); err == nil {
+			continue
+		}
+		keys = append(keys, key)
+	}
+	if len(keys) == 0 {
+		return "body"
+	}
+	return strings.Join(keys, ".")
+}
+
+// The messages are the ones of the validators in internal/data
+func schemaErrorMessage(schemaError *openapi3.SchemaError) string {
+	schema := schemaError.Schema
+
+	switch schemaError.SchemaField {
+	case "required":
+		return "must be provided"
+	case "type":
+		return typeErrorMessage(schema)
+	case "enum":
+		values := make([]string, len(schema.Enum))
+		for i, value := range schema.Enum {
+			values[i] = fmt.Sprint(value)
+		}
+		if len(values) == 2 {
+			return fmt.Sprintf("must be either %s or %s", values[0], values[1])
+		}
+		return "must be one of " + strings.Join(values, ", ")
+	case "format":
+		switch schema.Format {
+		case "date":
+			return "must be a date in the format YYYY-MM-DD"
+		case "email":
+			return "must be a valid email address"
+		case "uri":
+			return "must be a valid URL"
+		}
+		return "must be a valid " + schema.Format
+	case "minimum":
+		switch *schema.Min {
+		case 0:
+			return "must not be negative"
+		case 1:
+			return "must be greater than zero"
+		}
+		return fmt.Sprintf("must be at least %v", *schema.Min)
+	case "maximum":
+		return fmt.Sprintf("must be a maximum of %v", *schema.Max)
+	case "minLength":
+		if schema.MinLength == 1 {
+			return "must be provided"
+		}
+		return fmt.Sprintf("must be at least %d bytes long", schema.MinLength)
+	case "maxLength":
+		return fmt.Sprintf("must not be more than %d bytes long", *schema.MaxLength)
+	case "minItems":
+		if schema.MinItems == 1 {
+			return "must not be empty"
+		}
+		return fmt.Sprintf("must contain at least %d values", schema.MinItems)
+	case "maxItems":
+		return fmt.Sprintf("must not contain more than %d values", *schema.MaxItems)
+	case "uniqueItems":
+		return "must not contain duplicate values"
+	}
+	return schemaError.Reason
+}
+
+func typeErrorMessage(schema *openapi3.Schema) string {
+	switch {
+	case schema.Type.Is(openapi3.TypeInteger):
+		return "must be an integer value"
+	case schema.Type.Is(openapi3.TypeNumber):
+		return "must be a number"
+	case schema.Type.Is(openapi3.TypeBoolean):
+		return "must be true or false"
+	case schema.Type.Is(openapi3.TypeArray):
+		return "must be a list"
+	case schema.Type.Is(openapi3.TypeObject):
+		return "must be an object"
+	}
+	return "must be a string"
+}
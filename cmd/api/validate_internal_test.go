@@ -0,0 +1,109 @@
+// Filename: cmd/api/validate_internal_test.go
+
+package main
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestValidateRequest(t *testing.T) {
+	app := &application{openAPIRouter: newOpenAPIRouter()}
+
+	// the handler sends back the body it got
+	var handled bool
+	handler := app.validateRequest(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		handled = true
+		io.Copy(w, r.Body)
+	}))
+
+	tests := []struct {
+		name   string
+		method string
+		target string
+		body   string
+		want   map[string]string // nil when the handler gets the request
+	}{
+		{"valid listing", "GET", "/v1/quotes?page=2&page_size=20&sort=-id&tags_mode=all", "", nil},
+		{"empty page", "GET", "/v1/quotes?page=", "", nil},
+		{"page not a number", "GET", "/v1/quotes?page=two", "",
+			map[string]string{"page": "must be an integer value"}},
+		{"page zero", "GET", "/v1/quotes?page=0", "",
+			map[string]string{"page": "must be greater than zero"}},
+		{"page size too big", "GET", "/v1/quotes?page_size=101", "",
+			map[string]string{"page_size": "must be a maximum of 100"}},
+		{"many errors", "GET", "/v1/quotes?fuzzy=maybe&tags_mode=some", "",
+			map[string]string{"fuzzy": "must be true or false", "tags_mode": "must be either any or all"}},
+		{"valid quote", "POST", "/v1/quotes", `{"content": "Do or do not", "author": "Yoda", "tags": ["life"]}`, nil},
+		{"missing content", "POST", "/v1/quotes", `{"author": "Yoda"}`,
+			map[string]string{"content": "must be provided"}},
+		{"content not a string", "POST", "/v1/quotes", `{"content": 7, "author": "Yoda"}`,
+			map[string]string{"content": "must be a string"}},
+		{"tag too long", "POST", "/v1/quotes", `{"content": "Do or do not", "tags": ["` + strings.Repeat("a", 31) + `"]}`,
+			map[string]string{"tags": "must not be more than 30 bytes long"}},
+		{"body not an object", "POST", "/v1/quotes", `["Do or do not"]`,
+			map[string]string{"body": "must be an object"}},
+		// readJSON() answers these
+		{"unknown key", "POST", "/v1/quotes", `{"content": "Do or do not", "said": "Yoda"}`, nil},
+		{"null for no change", "PATCH", "/v1/quotes/1", `{"author": null}`, nil},
+		{"badly-formed body", "POST", "/v1/quotes", `{"content": `, nil},
+		{"import", "POST", "/v1/quotes/import", "content\nDo or do not\n", nil},
+		// the router and the handlers answer these
+		{"id not a number", "GET", "/v1/quotes/one", "", nil},
+		{"unknown path", "GET", "/v1/nothing?page=two", "", nil},
+		{"unknown method", "PUT", "/v1/quotes?page=two", "", nil},
+	}
+
+	for _, tt := range tests {
+		handled = false
+		r := httptest.NewRequest(tt.method, tt.target, strings.NewReader(tt.body))
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, r)
+
+		if tt.want == nil {
+			if !handled {
+				t.Errorf("%s: expected: the handler to run, got: %d %s", tt.name, w.Code, w.Body)
+				continue
+			}
+			if w.Body.String() != tt.body {
+				t.Errorf("%s: expected: %q, got: %q", tt.name, tt.body, w.Body)
+			}
+			continue
+		}
+
+		if handled || w.Code != http.StatusUnprocessableEntity {
+			t.Errorf("%s: expected: %d, got: %d", tt.name, http.StatusUnprocessableEntity, w.Code)
+			continue
+		}
+		var got struct {
+			Error map[string]string `json:"error"`
+		}
+		err := json.NewDecoder(w.Body).Decode(&got)
+		if err != nil {
+			t.Fatalf("%s: unexpected error: %v", tt.name, err)
+		}
+		if !reflect.DeepEqual(got.Error, tt.want) {
+			t.Errorf("%s: expected: %q, got: %q", tt.name, tt.want, got.Error)
+		}
+	}
+}
+
+// Who may not use a route gets the 401/403 of the route, not a list of
+// what the route takes
+func TestValidateRequestAfterAuthentication(t *testing.T) {
+	app := &application{openAPIRouter: newOpenAPIRouter()}
+	routes := app.routes()
+
+	r := httptest.NewRequest("POST", "/v1/quotes", strings.NewReader(`{}`))
+	w := httptest.NewRecorder()
+	routes.ServeHTTP(w, r)
+
+	if w.Code != http.StatusUnauthorized {
+		t.Errorf("expected: %d, got: %d %s", http.StatusUnauthorized, w.Code, w.Body)
+	}
+}
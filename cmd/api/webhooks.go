@@ -0,0 +1,315 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/2016114132/qod/internal/data"
+	"github.com/2016114132/qod/internal/validator"
+	"github.com/julienschmidt/httprouter"
+)
+
+// Register a URL for quote events. Without a secret we make one up. The
+// secret is in this response only, so the client must keep it
+func (a *application) createWebhookHandler(w http.ResponseWriter,
+	r *http.Request) {
+	var incomingData struct {
+		URL    string   `json:"url"`
+		Events []string `json:"events"`
+		Secret string   `json:"secret"`
+	}
+	err := a.readJSON(w, r, &incomingData)
+	if err != nil {
+		a.badRequestResponse(w, r, err)
+		return
+	}
+
+	webhook := &data.Webhook{
+		UserID: a.contextGetUser(r).ID,
+		URL:    incomingData.URL,
+		Events: incomingData.Events,
+		Secret: incomingData.Secret,
+		Active: true,
+	}
+	if webhook.Secret == "" {
+		webhook.Secret, err = data.GenerateWebhookSecret()
+		if err != nil {
+			a.serverErrorResponse(w, r, err)
+			return
+		}
+	}
+
+	v := validator.New()
+	data.ValidateWebhook(v, webhook)
+	data.ValidateWebhookHost(v, webhook)
+	data.ValidateWebhookSecret(v, webhook.Secret)
+	if !v.IsEmpty() {
+		a.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	err = a.webhookModel.Insert(webhook)
+	if err != nil {
+		a.serverErrorResponse(w, r, err)
+		return
+	}
+
+	headers := make(http.Header)
+	headers.Set("Location", fmt.Sprintf("/v1/webhooks/%d", webhook.ID))
+
+	data := envelope{
+		"webhook": webhook,
+	}
+	err = a.writeJSON(w, http.StatusCreated, data, headers)
+	if err != nil {
+		a.serverErrorResponse(w, r, err)
+	}
+}
+
+func (a *application) displayWebhookHandler(w http.ResponseWriter,
+	r *http.Request) {
+	webhook, ok := a.readWebhook(w, r)
+	if !ok {
+		return
+	}
+
+	data := envelope{
+		"webhook": webhook,
+	}
+	err := a.writeJSON(w, http.StatusOK, data, nil)
+	if err != nil {
+		a.serverErrorResponse(w, r, err)
+	}
+}
+
+func (a *application) listWebhooksHandler(w http.ResponseWriter,
+	r *http.Request) {
+	var filters data.Filters
+	queryParameters := r.URL.Query()
+
+	v := validator.New()
+
+	filters.Page = a.getSingleIntegerParameter(queryParameters, "page", 1, v)
+	filters.PageSize = a.getSingleIntegerParameter(queryParameters, "page_size", 10, v)
+	filters.Sort = a.getSingleQueryParameter(queryParameters, "sort", "id")
+	filters.SortSafeList = []string{"id", "url", "created_at", "-id", "-url", "-created_at"}
+
+	data.ValidateFilters(v, filters)
+	if !v.IsEmpty() {
+		a.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	webhooks, metadata, err := a.webhookModel.GetAllForUser(a.contextGetUser(r).ID, filters)
+	if err != nil {
+		a.serverErrorResponse(w, r, err)
+		return
+	}
+
+	data := envelope{
+		"webhooks":  webhooks,
+		"@metadata": metadata,
+	}
+	err = a.writeJSON(w, http.StatusOK, data, nil)
+	if err != nil {
+		a.serverErrorResponse(w, r, err)
+	}
+}
+
+// Change the URL or the events of a webhook, or turn it off and on. The
+// deliveries of a webhook that is off wait until it is on again
+func (a *application) updateWebhookHandler(w http.ResponseWriter,
+	r *http.Request) {
+	webhook, ok := a.readWebhook(w, r)
+	if !ok {
+		return
+	}
+
+	var incomingData struct {
+		URL    *string   `json:"url"`
+		Events *[]string `json:"events"`
+		Active *bool     `json:"active"`
+	}
+	err := a.readJSON(w, r, &incomingData)
+	if err != nil {
+		a.badRequestResponse(w, r, err)
+		return
+	}
+	if incomingData.URL != nil {
+		webhook.URL = *incomingData.URL
+	}
+	if incomingData.Events != nil {
+		webhook.Events = *incomingData.Events
+	}
+	if incomingData.Active != nil {
+		webhook.Active = *incomingData.Active
+	}
+
+	v := validator.New()
+	data.ValidateWebhook(v, webhook)
+	// the host is only looked up again when the URL changes
+	if incomingData.URL != nil {
+		data.ValidateWebhookHost(v, webhook)
+	}
+	if !v.IsEmpty() {
+		a.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	err = a.webhookModel.Update(webhook)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrEditConflict):
+			a.editConflictResponse(w, r)
+		default:
+			a.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	data := envelope{
+		"webhook": webhook,
+	}
+	err = a.writeJSON(w, http.StatusOK, data, nil)
+	if err != nil {
+		a.serverErrorResponse(w, r, err)
+	}
+}
+
+func (a *application) deleteWebhookHandler(w http.ResponseWriter,
+	r *http.Request) {
+	id, err := a.readIDParam(r)
+	if err != nil {
+		a.notFoundResponse(w, r)
+		return
+	}
+	err = a.webhookModel.Delete(id, a.contextGetUser(r).ID)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			a.notFoundResponse(w, r)
+		default:
+			a.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	data := envelope{
+		"message": "webhook successfully deleted",
+	}
+	err = a.writeJSON(w, http.StatusOK, data, nil)
+	if err != nil {
+		a.serverErrorResponse(w, r, err)
+	}
+}
+
+// List what was sent to a webhook, the newest delivery first. The
+// status query parameter picks the pending, succeeded or failed ones
+func (a *application) listDeliveriesHandler(w http.ResponseWriter,
+	r *http.Request) {
+	webhook, ok := a.readWebhook(w, r)
+	if !ok {
+		return
+	}
+
+	var filters data.Filters
+	queryParameters := r.URL.Query()
+
+	v := validator.New()
+
+	status := a.getSingleQueryParameter(queryParameters, "status", "")
+	if status != "" {
+		v.Check(validator.PermittedValue(status, data.DeliveryStatuses...), "status",
+			"must be pending, succeeded or failed")
+	}
+
+	filters.Page = a.getSingleIntegerParameter(queryParameters, "page", 1, v)
+	filters.PageSize = a.getSingleIntegerParameter(queryParameters, "page_size", 10, v)
+	filters.Sort = a.getSingleQueryParameter(queryParameters, "sort", "-id")
+	filters.SortSafeList = []string{"id", "-id"}
+
+	data.ValidateFilters(v, filters)
+	if !v.IsEmpty() {
+		a.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	deliveries, metadata, err := a.deliveryModel.GetAllForWebhook(webhook.ID, status, filters)
+	if err != nil {
+		a.serverErrorResponse(w, r, err)
+		return
+	}
+
+	data := envelope{
+		"deliveries": deliveries,
+		"@metadata":  metadata,
+	}
+	err = a.writeJSON(w, http.StatusOK, data, nil)
+	if err != nil {
+		a.serverErrorResponse(w, r, err)
+	}
+}
+
+// Send a delivery again, for instance after the receiver was fixed.
+// It goes out as a new delivery with its own attempts
+func (a *application) replayDeliveryHandler(w http.ResponseWriter,
+	r *http.Request) {
+	webhook, ok := a.readWebhook(w, r)
+	if !ok {
+		return
+	}
+	params := httprouter.ParamsFromContext(r.Context())
+	deliveryID, err := strconv.ParseInt(params.ByName("delivery_id"), 10, 64)
+	if err != nil || deliveryID < 1 {
+		a.notFoundResponse(w, r)
+		return
+	}
+
+	delivery, err := a.deliveryModel.Replay(webhook.ID, deliveryID)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			a.notFoundResponse(w, r)
+		default:
+			a.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+	a.wakeWebhookWorker()
+
+	data := envelope{
+		"delivery": delivery,
+	}
+	err = a.writeJSON(w, http.StatusAccepted, data, nil)
+	if err != nil {
+		a.serverErrorResponse(w, r, err)
+	}
+}
+
+// Get the webhook from /v1/webhooks/:id. Users only get their own
+// webhooks. If it does not exist, or something went wrong, the response
+// is sent and we return false
+func (a *application) readWebhook(w http.ResponseWriter,
+	r *http.Request) (*data.Webhook, bool) {
+
+	id, err := a.readIDParam(r)
+	if err != nil {
+		a.notFoundResponse(w, r)
+		return nil, false
+	}
+
+	webhook, err := a.webhookModel.Get(id, a.contextGetUser(r).ID)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			a.notFoundResponse(w, r)
+		default:
+			a.serverErrorResponse(w, r, err)
+		}
+		return nil, false
+	}
+
+	return webhook, true
+}
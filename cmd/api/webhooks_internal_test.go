@@ -0,0 +1,166 @@
+// Filename: cmd/api/webhooks_internal_test.go
+
+package main
+
+import (
+	"context"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/2016114132/qod/internal/data"
+)
+
+func TestSendDelivery(t *testing.T) {
+	const secret = "0123456789abcdef0123456789abcdef"
+	payload := []byte(`{"event":"quote.created","quote_id":1}`)
+
+	status := http.StatusNoContent
+	receiver := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, err := io.ReadAll(r.Body)
+		if err != nil {
+			t.Fatal(err)
+		}
+		timestamp, err := strconv.ParseInt(r.Header.Get("X-QOD-Timestamp"), 10, 64)
+		if err != nil {
+			t.Errorf("expected: a timestamp, got: %q", r.Header.Get("X-QOD-Timestamp"))
+		}
+		want := signWebhook(secret, timestamp, body)
+		if got := r.Header.Get("X-QOD-Signature"); got != want {
+			t.Errorf("expected: %q, got: %q", want, got)
+		}
+		if got := r.Header.Get("X-QOD-Event"); got != "quote.created" {
+			t.Errorf("expected: %q, got: %q", "quote.created", got)
+		}
+		w.WriteHeader(status)
+	}))
+	defer receiver.Close()
+
+	app := &application{webhookClient: receiver.Client()}
+	delivery := &data.WebhookDelivery{
+		ID:      1,
+		Event:   data.QuoteCreated,
+		Payload: payload,
+		Status:  data.DeliveryPending,
+		URL:     receiver.URL,
+		Secret:  secret,
+	}
+
+	app.sendDelivery(context.Background(), delivery)
+	if delivery.Status != data.DeliverySucceeded {
+		t.Errorf("expected: %q, got: %q", data.DeliverySucceeded, delivery.Status)
+	}
+	if delivery.ResponseStatus == nil || *delivery.ResponseStatus != http.StatusNoContent {
+		t.Errorf("expected: %d, got: %v", http.StatusNoContent, delivery.ResponseStatus)
+	}
+
+	// a failed attempt is tried again later, until we run out of attempts
+	status = http.StatusInternalServerError
+	delivery.Status = data.DeliveryPending
+	delivery.Attempts = 0
+
+	app.sendDelivery(context.Background(), delivery)
+	if delivery.Status != data.DeliveryPending {
+		t.Errorf("expected: %q, got: %q", data.DeliveryPending, delivery.Status)
+	}
+	if delivery.NextAttemptAt == nil || time.Until(*delivery.NextAttemptAt) <= 0 {
+		t.Errorf("expected: a retry, got: %v", delivery.NextAttemptAt)
+	}
+	if delivery.LastError == "" {
+		t.Errorf("expected: an error, got: none")
+	}
+
+	delivery.Attempts = webhookMaxAttempts - 1
+	app.sendDelivery(context.Background(), delivery)
+	if delivery.Status != data.DeliveryFailed {
+		t.Errorf("expected: %q, got: %q", data.DeliveryFailed, delivery.Status)
+	}
+	if delivery.NextAttemptAt != nil {
+		t.Errorf("expected: no retry, got: %v", delivery.NextAttemptAt)
+	}
+}
+
+func TestWebhookBackoff(t *testing.T) {
+	tests := []struct {
+		attempts int
+		want     time.Duration
+	}{
+		{1, 30 * time.Second},
+		{2, time.Minute},
+		{3, 2 * time.Minute},
+		{7, 32 * time.Minute},
+	}
+
+	for _, tt := range tests {
+		got := webhookBackoff(tt.attempts)
+		if got != tt.want {
+			t.Errorf("%d attempts: expected: %s, got: %s", tt.attempts, tt.want, got)
+		}
+	}
+}
+
+// The worker must not reach into our own network, whatever the name of
+// the webhook resolves to when it connects
+func TestWebhookClientRefusesLocalAddresses(t *testing.T) {
+	receiver := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.Error("expected: no delivery, got: one")
+	}))
+	defer receiver.Close()
+
+	app := &application{webhookClient: newWebhookClient()}
+	delivery := &data.WebhookDelivery{
+		ID:      1,
+		Event:   data.QuoteCreated,
+		Payload: []byte(`{}`),
+		Status:  data.DeliveryPending,
+		URL:     receiver.URL,
+		Secret:  "0123456789abcdef",
+	}
+
+	app.sendDelivery(context.Background(), delivery)
+	if delivery.ResponseStatus != nil {
+		t.Errorf("expected: no response, got: %d", *delivery.ResponseStatus)
+	}
+	if !strings.Contains(delivery.LastError, "not a public address") {
+		t.Errorf("expected: %q, got: %q", "not a public address", delivery.LastError)
+	}
+}
+
+func TestPublicIP(t *testing.T) {
+	tests := []struct {
+		address string
+		want    bool
+	}{
+		{"93.184.216.34", true},
+		{"2606:2800:220:1:248:1893:25c8:1946", true},
+		{"127.0.0.1", false},
+		{"::1", false},
+		{"10.1.2.3", false},
+		{"172.16.0.1", false},
+		{"192.168.1.1", false},
+		{"169.254.169.254", false},
+		{"fe80::1", false},
+		{"fd00::1", false},
+		{"0.0.0.0", false},
+		{"::ffff:127.0.0.1", false},
+		{"0.1.2.3", false},
+		{"100.64.0.1", false},
+		{"100.127.255.254", false},
+		{"100.128.0.1", true},
+		{"198.18.0.1", false},
+		{"198.19.255.254", false},
+		{"64:ff9b::a00:1", false},
+	}
+
+	for _, tt := range tests {
+		got := data.PublicIP(net.ParseIP(tt.address))
+		if got != tt.want {
+			t.Errorf("%s: expected: %v, got: %v", tt.address, tt.want, got)
+		}
+	}
+}
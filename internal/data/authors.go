@@ -0,0 +1,432 @@
+package data
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/2016114132/qod/internal/validator"
+	"github.com/lib/pq"
+)
+
+// An author of quotes. The slug is derived from the name and is what
+// makes two spellings of a name the same author. Aliases are the other
+// names the author is known by ("M. Twain" for "Mark Twain")
+type Author struct {
+	ID        int64     `json:"id"`
+	Name      string    `json:"name"`
+	Slug      string    `json:"slug"`
+	Aliases   []string  `json:"aliases"`
+	Bio       string    `json:"bio"`
+	BirthYear *int32    `json:"birth_year"`
+	DeathYear *int32    `json:"death_year"`
+	CreatedAt time.Time `json:"-"`
+	Version   int32     `json:"version"`
+}
+
+// Specify a custom error for when another author already uses the slug
+var ErrDuplicateSlug = errors.New("duplicate slug")
+
+// Turn a name into a slug: "Mark Twain!" -> "mark-twain". Any run of
+// characters that are not letters or digits becomes a single dash.
+// The backfill migration does the same thing in SQL
+func Slugify(name string) string {
+	var slug strings.Builder
+	dash := false
+	for _, r := range strings.ToLower(name) {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			slug.WriteRune(r)
+			dash = false
+			continue
+		}
+		if !dash && slug.Len() > 0 {
+			slug.WriteRune('-')
+			dash = true
+		}
+	}
+	return strings.TrimSuffix(slug.String(), "-")
+}
+
+func ValidateAuthor(v *validator.Validator, author *Author) {
+	v.Check(author.Name != "", "name", "must be provided")
+	// an author's name is also the author of their quotes
+	v.Check(len(author.Name) <= 25, "name", "must not be more than 25 bytes long")
+	v.Check(author.Name == "" || author.Slug != "", "name", "must contain at least one letter or digit")
+
+	v.Check(len(author.Aliases) <= 20, "aliases", "must not contain more than 20 aliases")
+	v.Check(validator.Unique(author.Aliases), "aliases", "must not contain duplicate values")
+	for _, alias := range author.Aliases {
+		v.Check(alias != "", "aliases", "must not contain empty values")
+		v.Check(len(alias) <= 25, "aliases", "must not contain aliases more than 25 bytes long")
+	}
+
+	v.Check(len(author.Bio) <= 1000, "bio", "must not be more than 1000 bytes long")
+
+	// years before the common era are negative
+	currentYear := int32(time.Now().Year())
+	if author.BirthYear != nil {
+		v.Check(*author.BirthYear <= currentYear, "birth_year", "must not be in the future")
+	}
+	if author.DeathYear != nil {
+		v.Check(*author.DeathYear <= currentYear, "death_year", "must not be in the future")
+		if author.BirthYear != nil {
+			v.Check(*author.DeathYear >= *author.BirthYear, "death_year",
+				"must not be before the birth year")
+		}
+	}
+}
+
+// Find the author of a quote by name (or one of their aliases) and link
+// the quote to them. If we have never seen the author we create them.
+// This runs inside the transaction of the quote insert/update
+func linkQuoteAuthor(ctx context.Context, tx *sql.Tx, quote *Quote) error {
+	if quote.AuthorID != nil {
+		return nil
+	}
+	slug := Slugify(quote.Author)
+	if slug == "" {
+		return nil
+	}
+
+	query := `
+        SELECT id, name FROM authors
+        WHERE slug = $1
+        OR EXISTS (SELECT 1 FROM unnest(aliases) AS alias
+                   WHERE lower(alias) = lower($2))
+        ORDER BY slug = $1 DESC, id ASC
+        LIMIT 1
+      `
+	var authorID int64
+	err := tx.QueryRowContext(ctx, query, slug, quote.Author).Scan(&authorID, &quote.Author)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return err
+	}
+
+	if errors.Is(err, sql.ErrNoRows) {
+		// a new author. If someone else just created the same author
+		// the conflict hands us their row instead
+		query = `
+            INSERT INTO authors (name, slug)
+            VALUES ($1, $2)
+            ON CONFLICT (slug) DO UPDATE SET slug = EXCLUDED.slug
+            RETURNING id, name
+            `
+		err = tx.QueryRowContext(ctx, query, quote.Author, slug).Scan(&authorID, &quote.Author)
+		if err != nil {
+			return err
+		}
+	}
+
+	quote.AuthorID = &authorID
+	return nil
+}
+
+// Setup our model
+type AuthorModel struct {
+	DB *sql.DB
+}
+
+// Insert a new author
+func (a AuthorModel) Insert(author *Author) error {
+	query := `
+        INSERT INTO authors (name, slug, aliases, bio, birth_year, death_year)
+        VALUES ($1, $2, $3, $4, $5, $6)
+        RETURNING id, created_at, version
+        `
+	args := []any{author.Name, author.Slug, pq.Array(author.Aliases),
+		author.Bio, author.BirthYear, author.DeathYear}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	err := a.DB.QueryRowContext(ctx, query, args...).Scan(
+		&author.ID,
+		&author.CreatedAt,
+		&author.Version)
+	if err != nil {
+		switch {
+		case err.Error() == `pq: duplicate key value violates unique constraint "authors_slug_key"`:
+			return ErrDuplicateSlug
+		default:
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Get a specific author
+func (a AuthorModel) Get(id int64) (*Author, error) {
+	if id < 1 {
+		return nil, ErrRecordNotFound
+	}
+	query := `
+        SELECT id, created_at, name, slug, aliases, bio,
+               birth_year, death_year, version
+        FROM authors
+        WHERE id = $1
+      `
+	var author Author
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	err := a.DB.QueryRowContext(ctx, query, id).Scan(
+		&author.ID,
+		&author.CreatedAt,
+		&author.Name,
+		&author.Slug,
+		pq.Array(&author.Aliases),
+		&author.Bio,
+		&author.BirthYear,
+		&author.DeathYear,
+		&author.Version,
+	)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrRecordNotFound
+		default:
+			return nil, err
+		}
+	}
+
+	return &author, nil
+}
+
+// Get the authors with the ids in one go, by id. The ids that have no
+// author are left out
+func (a AuthorModel) GetMany(ids []int64) (map[int64]*Author, error) {
+	query := `
+        SELECT id, created_at, name, slug, aliases, bio,
+               birth_year, death_year, version
+        FROM authors
+        WHERE id = ANY($1)
+      `
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	rows, err := a.DB.QueryContext(ctx, query, pq.Array(ids))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	authors := make(map[int64]*Author, len(ids))
+	for rows.Next() {
+		var author Author
+		err := rows.Scan(
+			&author.ID,
+			&author.CreatedAt,
+			&author.Name,
+			&author.Slug,
+			pq.Array(&author.Aliases),
+			&author.Bio,
+			&author.BirthYear,
+			&author.DeathYear,
+			&author.Version,
+		)
+		if err != nil {
+			return nil, err
+		}
+		authors[author.ID] = &author
+	}
+	err = rows.Err()
+	if err != nil {
+		return nil, err
+	}
+
+	return authors, nil
+}
+
+// Get all authors, optionally searching their names and aliases
+func (a AuthorModel) GetAll(name string, filters Filters) ([]*Author, Metadata, error) {
+	query := fmt.Sprintf(`
+        SELECT COUNT(*) OVER(), id, created_at, name, slug, aliases, bio,
+               birth_year, death_year, version
+        FROM authors
+        WHERE (to_tsvector('simple', name || ' ' || array_to_string(aliases, ' ')) @@
+               plainto_tsquery('simple', $1) OR $1 = '')
+        ORDER BY %s %s, id ASC
+        LIMIT $2 OFFSET $3`, filters.sortColumn(), filters.sortDirection())
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	rows, err := a.DB.QueryContext(ctx, query, name, filters.limit(), filters.offset())
+	if err != nil {
+		return nil, Metadata{}, err
+	}
+	defer rows.Close()
+
+	totalRecords := 0
+	authors := []*Author{}
+
+	for rows.Next() {
+		var author Author
+		err := rows.Scan(
+			&totalRecords,
+			&author.ID,
+			&author.CreatedAt,
+			&author.Name,
+			&author.Slug,
+			pq.Array(&author.Aliases),
+			&author.Bio,
+			&author.BirthYear,
+			&author.DeathYear,
+			&author.Version,
+		)
+		if err != nil {
+			return nil, Metadata{}, err
+		}
+		authors = append(authors, &author)
+	}
+	err = rows.Err()
+	if err != nil {
+		return nil, Metadata{}, err
+	}
+
+	metadata := calculateMetaData(totalRecords, filters.Page, filters.PageSize)
+
+	return authors, metadata, nil
+}
+
+// An author whose name looks like what the user is typing. The score
+// goes from 0 (nothing in common) to 1 (the same)
+type AuthorSuggestion struct {
+	ID    int64   `json:"id"`
+	Name  string  `json:"name"`
+	Slug  string  `json:"slug"`
+	Score float32 `json:"score"`
+}
+
+// Get the authors whose names (or aliases) are the most similar to the
+// search, for autocompletion. Trigrams forgive typos, so "Einstien"
+// still finds "Albert Einstein". An author scores as well as their
+// closest spelling, and is suggested under their name
+func (a AuthorModel) Suggest(search string, limit int) ([]*AuthorSuggestion, error) {
+	query := `
+        SELECT authors.id, authors.name, authors.slug, best.score
+        FROM authors
+        CROSS JOIN LATERAL (
+            SELECT MAX(GREATEST(similarity(spelling, $1),
+                                word_similarity($1, spelling))) AS score
+            FROM unnest(array_prepend(authors.name, authors.aliases)) AS spelling
+            WHERE $1 <% spelling OR spelling % $1
+        ) AS best
+        WHERE best.score IS NOT NULL
+        ORDER BY best.score DESC, authors.name ASC
+        LIMIT $2
+      `
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	rows, err := a.DB.QueryContext(ctx, query, search, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	suggestions := []*AuthorSuggestion{}
+	for rows.Next() {
+		var suggestion AuthorSuggestion
+		err := rows.Scan(&suggestion.ID, &suggestion.Name, &suggestion.Slug, &suggestion.Score)
+		if err != nil {
+			return nil, err
+		}
+		suggestions = append(suggestions, &suggestion)
+	}
+	err = rows.Err()
+	if err != nil {
+		return nil, err
+	}
+
+	return suggestions, nil
+}
+
+// Update an author. The quotes keep a copy of their author's name so a
+// new name is copied over to them as well. That is a new version of
+// each of those quotes (the ETag of a quote is its version), made by
+// the editor of the author
+func (a AuthorModel) Update(author *Author, editorID int64) error {
+	query := `
+        UPDATE authors
+        SET name = $1, slug = $2, aliases = $3, bio = $4,
+            birth_year = $5, death_year = $6, version = version + 1
+        WHERE id = $7 AND version = $8
+        RETURNING version
+        `
+	args := []any{author.Name, author.Slug, pq.Array(author.Aliases), author.Bio,
+		author.BirthYear, author.DeathYear, author.ID, author.Version}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	tx, err := a.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	err = tx.QueryRowContext(ctx, query, args...).Scan(&author.Version)
+	if err != nil {
+		switch {
+		case err.Error() == `pq: duplicate key value violates unique constraint "authors_slug_key"`:
+			return ErrDuplicateSlug
+		case errors.Is(err, sql.ErrNoRows):
+			return ErrEditConflict
+		default:
+			return err
+		}
+	}
+
+	query = `
+        WITH renamed AS (
+            UPDATE quotes
+            SET author = $1, updated_at = NOW(), version = version + 1
+            WHERE author_id = $2 AND author <> $1
+            RETURNING id, version, content, author
+        )
+        INSERT INTO quote_revisions (quote_id, version, content, author, editor_id)
+        SELECT id, version, content, author, $3
+        FROM renamed
+        `
+	_, err = tx.ExecContext(ctx, query, author.Name, author.ID, editorID)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// Delete an author. Their quotes keep the author's name but are no
+// longer linked to an author
+func (a AuthorModel) Delete(id int64) error {
+	if id < 1 {
+		return ErrRecordNotFound
+	}
+	query := `
+        DELETE FROM authors
+        WHERE id = $1
+      `
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	result, err := a.DB.ExecContext(ctx, query, id)
+	if err != nil {
+		return err
+	}
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rowsAffected == 0 {
+		return ErrRecordNotFound
+	}
+
+	return nil
+}
@@ -0,0 +1,33 @@
+// Filename: internal/data/authors_internal_test.go
+
+package data
+
+import "testing"
+
+func TestSlugify(t *testing.T) {
+	tests := []struct {
+		name string
+		want string
+	}{
+		{"Mark Twain", "mark-twain"},
+		{"Mark Twain!", "mark-twain"},
+		{"  Mark   Twain  ", "mark-twain"},
+		{"Mark -- Twain", "mark-twain"},
+		{"Martin Luther King, Jr.", "martin-luther-king-jr"},
+		{"O'Brien", "o-brien"},
+		{"Émile Zola", "émile-zola"},
+		{"Søren Kierkegaard", "søren-kierkegaard"},
+		{"孔子", "孔子"},
+		{"Pope John Paul II", "pope-john-paul-ii"},
+		{"50 Cent", "50-cent"},
+		{"!!!", ""},
+		{"", ""},
+	}
+
+	for _, tt := range tests {
+		got := Slugify(tt.name)
+		if got != tt.want {
+			t.Errorf("%s: expected: %q, got: %q", tt.name, tt.want, got)
+		}
+	}
+}
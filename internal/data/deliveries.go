@@ -0,0 +1,262 @@
+package data
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// Where a delivery is at. A pending delivery is tried until it succeeds
+// or runs out of attempts, then it has failed
+const (
+	DeliveryPending   = "pending"
+	DeliverySucceeded = "succeeded"
+	DeliveryFailed    = "failed"
+)
+
+var DeliveryStatuses = []string{DeliveryPending, DeliverySucceeded, DeliveryFailed}
+
+// What we POST to the webhooks. Quote is left out for deleted quotes
+// and Date is only there for featured quotes
+type WebhookPayload struct {
+	Event      string    `json:"event"`
+	QuoteID    int64     `json:"quote_id"`
+	Quote      *Quote    `json:"quote,omitempty"`
+	Date       string    `json:"date,omitempty"`
+	OccurredAt time.Time `json:"occurred_at"`
+}
+
+// An event sent (or to be sent) to a webhook. URL and Secret come
+// along for the worker that sends it
+type WebhookDelivery struct {
+	ID             int64           `json:"id"`
+	WebhookID      int64           `json:"webhook_id"`
+	Event          string          `json:"event"`
+	Payload        json.RawMessage `json:"payload"`
+	Status         string          `json:"status"`
+	Attempts       int             `json:"attempts"`
+	NextAttemptAt  *time.Time      `json:"next_attempt_at"` // nil unless pending
+	LastAttemptAt  *time.Time      `json:"last_attempt_at"`
+	ResponseStatus *int            `json:"response_status"`
+	LastError      string          `json:"last_error,omitempty"`
+	CreatedAt      time.Time       `json:"created_at"`
+	URL            string          `json:"-"`
+	Secret         string          `json:"-"`
+}
+
+// The columns of a delivery in the order of scanTargets()
+const deliveryColumns = `
+        webhook_deliveries.id, webhook_deliveries.webhook_id,
+        webhook_deliveries.event, webhook_deliveries.payload,
+        webhook_deliveries.status, webhook_deliveries.attempts,
+        CASE WHEN webhook_deliveries.status = 'pending'
+             THEN webhook_deliveries.next_attempt_at END,
+        webhook_deliveries.last_attempt_at, webhook_deliveries.response_status,
+        webhook_deliveries.last_error, webhook_deliveries.created_at`
+
+func (d *WebhookDelivery) scanTargets() []any {
+	return []any{
+		&d.ID,
+		&d.WebhookID,
+		&d.Event,
+		&d.Payload,
+		&d.Status,
+		&d.Attempts,
+		&d.NextAttemptAt,
+		&d.LastAttemptAt,
+		&d.ResponseStatus,
+		&d.LastError,
+		&d.CreatedAt,
+	}
+}
+
+type WebhookDeliveryModel struct {
+	DB *sql.DB
+}
+
+// Queue a delivery of the payload for every active webhook that wants
+// its event. The payload has the whole quote, so only the webhooks of
+// users who may read quotes get it. We return how many deliveries were
+// queued
+func (m WebhookDeliveryModel) Queue(payload WebhookPayload) (int64, error) {
+	js, err := json.Marshal(payload)
+	if err != nil {
+		return 0, err
+	}
+	query := `
+        INSERT INTO webhook_deliveries (webhook_id, event, payload)
+        SELECT webhooks.id, $1, $2
+        FROM webhooks
+        WHERE webhooks.active AND webhooks.events @> $3
+        AND EXISTS (
+            SELECT 1
+            FROM users_permissions
+            INNER JOIN permissions ON permissions.id = users_permissions.permission_id
+            WHERE users_permissions.user_id = webhooks.user_id
+            AND permissions.code = 'quotes:read'
+        )
+      `
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	result, err := m.DB.ExecContext(ctx, query, payload.Event, js,
+		pq.Array([]string{payload.Event}))
+	if err != nil {
+		return 0, err
+	}
+
+	return result.RowsAffected()
+}
+
+// Get the deliveries of a webhook, optionally only those with a status
+func (m WebhookDeliveryModel) GetAllForWebhook(webhookID int64, status string, filters Filters) ([]*WebhookDelivery, Metadata, error) {
+	query := fmt.Sprintf(`
+        SELECT COUNT(*) OVER(), %s
+        FROM webhook_deliveries
+        WHERE webhook_id = $1
+        AND (status = $2 OR $2 = '')
+        ORDER BY %s %s
+        LIMIT $3 OFFSET $4`, deliveryColumns, filters.sortColumn(), filters.sortDirection())
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query, webhookID, status, filters.limit(), filters.offset())
+	if err != nil {
+		return nil, Metadata{}, err
+	}
+	defer rows.Close()
+
+	totalRecords := 0
+	deliveries := []*WebhookDelivery{}
+
+	for rows.Next() {
+		var delivery WebhookDelivery
+		err := rows.Scan(append([]any{&totalRecords}, delivery.scanTargets()...)...)
+		if err != nil {
+			return nil, Metadata{}, err
+		}
+		deliveries = append(deliveries, &delivery)
+	}
+	err = rows.Err()
+	if err != nil {
+		return nil, Metadata{}, err
+	}
+
+	metadata := calculateMetaData(totalRecords, filters.Page, filters.PageSize)
+
+	return deliveries, metadata, nil
+}
+
+// Send a delivery again. The old delivery is kept as it is and a new
+// one with the same event and payload is queued
+func (m WebhookDeliveryModel) Replay(webhookID int64, deliveryID int64) (*WebhookDelivery, error) {
+	if deliveryID < 1 {
+		return nil, ErrRecordNotFound
+	}
+	query := `
+        INSERT INTO webhook_deliveries (webhook_id, event, payload)
+        SELECT webhook_id, event, payload
+        FROM webhook_deliveries
+        WHERE id = $1 AND webhook_id = $2
+        RETURNING ` + deliveryColumns
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	var delivery WebhookDelivery
+	err := m.DB.QueryRowContext(ctx, query, deliveryID, webhookID).Scan(delivery.scanTargets()...)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrRecordNotFound
+		default:
+			return nil, err
+		}
+	}
+
+	return &delivery, nil
+}
+
+// Take up to limit deliveries that are due. They are not due again for
+// the lease, so that another instance of the API does not send them at
+// the same time. RecordAttempt() sets when they are due next
+func (m WebhookDeliveryModel) ClaimDue(limit int, lease time.Duration) ([]*WebhookDelivery, error) {
+	query := `
+        WITH due AS (
+            SELECT webhook_deliveries.id
+            FROM webhook_deliveries
+            INNER JOIN webhooks ON webhooks.id = webhook_deliveries.webhook_id
+            WHERE webhook_deliveries.status = 'pending'
+            AND webhook_deliveries.next_attempt_at <= NOW()
+            AND webhooks.active
+            ORDER BY webhook_deliveries.next_attempt_at
+            LIMIT $1
+            FOR UPDATE OF webhook_deliveries SKIP LOCKED
+        )
+        UPDATE webhook_deliveries
+        SET next_attempt_at = NOW() + make_interval(secs => $2)
+        FROM due, webhooks
+        WHERE webhook_deliveries.id = due.id
+        AND webhooks.id = webhook_deliveries.webhook_id
+        RETURNING ` + deliveryColumns + `, webhooks.url, webhooks.secret`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query, limit, lease.Seconds())
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	deliveries := []*WebhookDelivery{}
+	for rows.Next() {
+		var delivery WebhookDelivery
+		err := rows.Scan(append(delivery.scanTargets(), &delivery.URL, &delivery.Secret)...)
+		if err != nil {
+			return nil, err
+		}
+		deliveries = append(deliveries, &delivery)
+	}
+	err = rows.Err()
+	if err != nil {
+		return nil, err
+	}
+
+	return deliveries, nil
+}
+
+// Save how the last attempt went: the status, the attempts, the response
+// status, the error and when the delivery is due next
+func (m WebhookDeliveryModel) RecordAttempt(delivery *WebhookDelivery) error {
+	query := `
+        UPDATE webhook_deliveries
+        SET status = $1, attempts = $2, response_status = $3, last_error = $4,
+            next_attempt_at = COALESCE($5, next_attempt_at), last_attempt_at = NOW()
+        WHERE id = $6
+        RETURNING last_attempt_at
+        `
+	args := []any{delivery.Status, delivery.Attempts, delivery.ResponseStatus,
+		delivery.LastError, delivery.NextAttemptAt, delivery.ID}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	err := m.DB.QueryRowContext(ctx, query, args...).Scan(&delivery.LastAttemptAt)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return ErrRecordNotFound
+		default:
+			return err
+		}
+	}
+
+	return nil
+}
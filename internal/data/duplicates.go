@@ -0,0 +1,173 @@
+package data
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// How similar (0 to 1) the normalized content of two quotes must be
+// for them to count as near duplicates
+const DuplicateSimilarity = 0.8
+
+// Quotes that look like copies of each other. Exact duplicates have the
+// same normalized content and a similarity of 1
+type DuplicateGroup struct {
+	QuoteIDs   []int64 `json:"quote_ids"`
+	Content    string  `json:"content"` // the content of the oldest quote
+	Similarity float32 `json:"similarity"`
+}
+
+// Specify a custom error for when a quote would duplicate another one
+var ErrDuplicateQuote = errors.New("duplicate quote")
+
+// The advisory lock that the duplicate check and the insert after it
+// hold, so that two requests can't both find no duplicate and then both
+// add the same quote. Near duplicates have no key that a unique index
+// could use, so the quotes that are checked are added one at a time
+const quoteDuplicateLock = 7_310_015
+
+// Insert the quote unless it would duplicate another quote, in which
+// case we get that quote and ErrDuplicateQuote. The check and the insert
+// run in one transaction, under quoteDuplicateLock
+func (c QuoteModel) InsertUnique(quote *Quote) (*Quote, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	tx, err := c.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	// does nothing if the transaction was committed
+	defer tx.Rollback()
+
+	err = lockDuplicateCheck(ctx, tx)
+	if err != nil {
+		return nil, err
+	}
+	existing, err := findDuplicate(ctx, tx, quote.Content)
+	switch {
+	case err == nil:
+		return existing, ErrDuplicateQuote
+	case !errors.Is(err, ErrRecordNotFound):
+		return nil, err
+	}
+
+	err = insertQuote(ctx, tx, quote)
+	if err != nil {
+		return nil, err
+	}
+
+	return nil, tx.Commit()
+}
+
+// Wait for any other duplicate check to finish. The lock is let go when
+// the transaction ends
+func lockDuplicateCheck(ctx context.Context, tx *sql.Tx) error {
+	_, err := tx.ExecContext(ctx, `SELECT pg_advisory_xact_lock($1)`, quoteDuplicateLock)
+	return err
+}
+
+// Anything we can run a single row query on, a *sql.DB or a *sql.Tx
+type rowQuerier interface {
+	QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row
+}
+
+// Get the quote that the content would duplicate. The content is
+// normalized by the database (see normalize_quote_content) and compared
+// to the content of the other quotes, first exactly and then by trigram
+// similarity. The closest match wins
+func findDuplicate(ctx context.Context, db rowQuerier, content string) (*Quote, error) {
+	query := `
+        SELECT ` + quoteColumns + `
+        FROM quotes
+        WHERE deleted_at IS NULL
+        AND (content_normalized = normalize_quote_content($1)
+             OR (content_normalized % normalize_quote_content($1)
+                 AND similarity(content_normalized, normalize_quote_content($1)) >= $2))
+        ORDER BY similarity(content_normalized, normalize_quote_content($1)) DESC, id ASC
+        LIMIT 1
+      `
+	var quote Quote
+
+	err := db.QueryRowContext(ctx, query, content, DuplicateSimilarity).Scan(quote.scanTargets()...)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrRecordNotFound
+		default:
+			return nil, err
+		}
+	}
+	return &quote, nil
+}
+
+// Get the quotes that are already duplicated, to clean them up. Exact
+// duplicates come in groups of any size. Near duplicates come in pairs,
+// the most similar first
+func (c QuoteModel) GetDuplicates(near bool, filters Filters) ([]*DuplicateGroup, Metadata, error) {
+	query := `
+        SELECT COUNT(*) OVER(), array_agg(id ORDER BY id),
+               (array_agg(content ORDER BY id))[1], 1
+        FROM quotes
+        WHERE deleted_at IS NULL
+        GROUP BY content_normalized
+        HAVING COUNT(*) > 1
+        ORDER BY COUNT(*) DESC, MIN(id) ASC
+        LIMIT $1 OFFSET $2`
+	args := []any{filters.limit(), filters.offset()}
+
+	if near {
+		query = `
+        SELECT COUNT(*) OVER(), ARRAY[first.id, second.id], first.content,
+               similarity(first.content_normalized, second.content_normalized) AS score
+        FROM quotes AS first
+        INNER JOIN quotes AS second
+                ON second.id > first.id
+               AND second.content_normalized % first.content_normalized
+        WHERE first.deleted_at IS NULL AND second.deleted_at IS NULL
+        AND first.content_normalized <> second.content_normalized
+        AND similarity(first.content_normalized, second.content_normalized) >= $3
+        ORDER BY score DESC, first.id ASC, second.id ASC
+        LIMIT $1 OFFSET $2`
+		args = append(args, DuplicateSimilarity)
+	}
+
+	// comparing every quote with the others takes a while
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	rows, err := c.DB.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, Metadata{}, err
+	}
+	defer rows.Close()
+
+	totalRecords := 0
+	groups := []*DuplicateGroup{}
+
+	for rows.Next() {
+		var group DuplicateGroup
+		err := rows.Scan(
+			&totalRecords,
+			pq.Array(&group.QuoteIDs),
+			&group.Content,
+			&group.Similarity,
+		)
+		if err != nil {
+			return nil, Metadata{}, err
+		}
+		groups = append(groups, &group)
+	}
+	err = rows.Err()
+	if err != nil {
+		return nil, Metadata{}, err
+	}
+
+	metadata := calculateMetaData(totalRecords, filters.Page, filters.PageSize)
+
+	return groups, metadata, nil
+}
@@ -6,3 +6,4 @@ import (
 
 var ErrRecordNotFound = errors.New("record not found")
 var ErrEditConflict = errors.New("edit conflict")
+var ErrNotPermitted = errors.New("not permitted")
@@ -0,0 +1,120 @@
+package data
+
+import (
+	"context"
+	"database/sql"
+	"time"
+)
+
+// The channel that the database announces new quote events on. The
+// payload is the id of the event
+const QuoteEventsChannel = "quote_events"
+
+// The types of quote events
+const (
+	QuoteCreated = "quote.created"
+	QuoteUpdated = "quote.updated"
+	QuoteDeleted = "quote.deleted"
+)
+
+// Something that happened to a quote. The events are recorded by a
+// trigger on the quotes table (see record_quote_event), so every change
+// has one, whichever instance of the API made it
+type QuoteEvent struct {
+	ID        int64     `json:"id"`
+	QuoteID   int64     `json:"quote_id"`
+	Type      string    `json:"type"`
+	CreatedAt time.Time `json:"created_at"`
+	Quote     *Quote    `json:"quote,omitempty"` // not there for deleted quotes
+}
+
+type QuoteEventModel struct {
+	DB *sql.DB
+}
+
+// Get the events that came after the event lastID, oldest first. The
+// ids are taken before the transactions commit, so they show up out of
+// order. Instead the events are in the order of their transactions
+// (xid), and we only give those of transactions older than every
+// transaction still going on: no event can turn up behind them later.
+// An id that we don't have (anymore) gives the events with a larger id.
+// It needs PostgreSQL 13 or later, and any long transaction holds back
+// the events of everyone until it ends
+func (c QuoteEventModel) GetAfter(lastID int64, limit int) ([]*QuoteEvent, error) {
+	query := `
+        WITH last AS (
+            SELECT xid, id
+            FROM quote_events
+            WHERE id = $1
+        )
+        SELECT id, quote_id, type, created_at
+        FROM quote_events
+        WHERE xid < pg_snapshot_xmin(pg_current_snapshot())
+        AND CASE WHEN EXISTS (SELECT 1 FROM last)
+                 THEN (xid, id) > (SELECT xid, id FROM last)
+                 ELSE id > $1 END
+        ORDER BY xid ASC, id ASC
+        LIMIT $2
+      `
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	rows, err := c.DB.QueryContext(ctx, query, lastID, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	events := []*QuoteEvent{}
+	for rows.Next() {
+		var event QuoteEvent
+		err := rows.Scan(&event.ID, &event.QuoteID, &event.Type, &event.CreatedAt)
+		if err != nil {
+			return nil, err
+		}
+		events = append(events, &event)
+	}
+	err = rows.Err()
+	if err != nil {
+		return nil, err
+	}
+
+	return events, nil
+}
+
+// The id of the newest event that GetAfter() can give, 0 if there are
+// none
+func (c QuoteEventModel) LatestID() (int64, error) {
+	query := `
+        SELECT COALESCE((
+            SELECT id
+            FROM quote_events
+            WHERE xid < pg_snapshot_xmin(pg_current_snapshot())
+            ORDER BY xid DESC, id DESC
+            LIMIT 1), 0)
+      `
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	var id int64
+	err := c.DB.QueryRowContext(ctx, query).Scan(&id)
+	return id, err
+}
+
+// Remove the events recorded before cutoff. Clients can't resume from
+// further back than the oldest event left
+func (c QuoteEventModel) DeleteBefore(cutoff time.Time) (int64, error) {
+	query := `
+        DELETE FROM quote_events
+        WHERE created_at < $1
+      `
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	result, err := c.DB.ExecContext(ctx, query, cutoff)
+	if err != nil {
+		return 0, err
+	}
+
+	return result.RowsAffected()
+}
@@ -0,0 +1,82 @@
+package data
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+// How long a whole export may take
+const ExportTimeout = 10 * time.Minute
+
+// How many quotes we fetch from the cursor at a time
+const exportBatchSize = 500
+
+// Go through every quote that matches the search terms, in the order of
+// filters.Sort, and hand each one to fn. Instead of loading them all we
+// read them through a server-side cursor, a batch at a time, so memory
+// use stays flat no matter how many quotes there are. If fn returns an
+// error the export stops with that error
+func (c QuoteModel) Export(quoteFilters QuoteFilters, filters Filters, fn func(*Quote) error) error {
+	filters.SortExpressions = quoteSortExpressions
+
+	ctx, cancel := context.WithTimeout(context.Background(), ExportTimeout)
+	defer cancel()
+
+	// a cursor only lives inside a transaction. Repeatable read gives us
+	// one snapshot of the quotes for the whole export
+	tx, err := c.DB.BeginTx(ctx, &sql.TxOptions{
+		Isolation: sql.LevelRepeatableRead,
+		ReadOnly:  true,
+	})
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	query := fmt.Sprintf(`
+        DECLARE quote_export NO SCROLL CURSOR FOR
+        SELECT %[1]s
+        FROM quotes
+        WHERE %[2]s
+        ORDER BY %[3]s %[4]s, id %[4]s`, quoteColumns, quoteFilterClause,
+		filters.sortColumn(), filters.sortDirection())
+
+	_, err = tx.ExecContext(ctx, query, quoteFilters.args()...)
+	if err != nil {
+		return err
+	}
+
+	fetch := fmt.Sprintf(`FETCH FORWARD %d FROM quote_export`, exportBatchSize)
+	for {
+		rows, err := tx.QueryContext(ctx, fetch)
+		if err != nil {
+			return err
+		}
+
+		fetched := 0
+		for rows.Next() {
+			var quote Quote
+			err := rows.Scan(quote.scanTargets()...)
+			if err == nil {
+				err = fn(&quote)
+			}
+			if err != nil {
+				rows.Close()
+				return err
+			}
+			fetched++
+		}
+		err = rows.Err()
+		rows.Close()
+		if err != nil {
+			return err
+		}
+
+		// a short batch means the cursor is used up
+		if fetched < exportBatchSize {
+			return nil
+		}
+	}
+}
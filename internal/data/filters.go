@@ -1,6 +1,10 @@
 package data
 
 import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
 	"strings"
 
 	"github.com/2016114132/qod/internal/validator"
@@ -13,15 +17,49 @@ type Filters struct {
 	PageSize     int // how records per page
 	Sort         string
 	SortSafeList []string // allowed sort fields
+	Cursor       string   // where to continue from, replaces Page when set
+	// SQL for the sort values that are not plain column names, keyed
+	// by the sort value without the "-"
+	SortExpressions map[string]string
 }
 
 // define a type to hold the metadata
 type Metadata struct {
-	CurrentPage  int `json:"current_page,omitempty"`
-	PageSize     int `json:"page_size,omitempty"`
-	FirstPage    int `json:"first_page,omitempty"`
-	LastPage     int `json:"last_page,omitempty"`
-	TotalRecords int `json:"total_records,omitempty"`
+	CurrentPage  int    `json:"current_page,omitempty"`
+	PageSize     int    `json:"page_size,omitempty"`
+	FirstPage    int    `json:"first_page,omitempty"`
+	LastPage     int    `json:"last_page,omitempty"`
+	TotalRecords int    `json:"total_records,omitempty"`
+	NextCursor   string `json:"next_cursor,omitempty"`
+}
+
+// A cursor remembers the last record that the client received: its
+// value for the sort column (as text) and its id. It is handed out as
+// base64 so that clients treat it as opaque and just send it back
+type cursor struct {
+	Sort  string `json:"s"`
+	Value string `json:"v"`
+	ID    int64  `json:"id"`
+}
+
+var errInvalidCursor = errors.New("invalid cursor")
+
+func encodeCursor(c cursor) string {
+	js, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(js)
+}
+
+func decodeCursor(s string) (cursor, error) {
+	var c cursor
+	js, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return c, errInvalidCursor
+	}
+	err = json.Unmarshal(js, &c)
+	if err != nil || c.ID < 1 {
+		return c, errInvalidCursor
+	}
+	return c, nil
 }
 
 // Calculate the metadata
@@ -53,13 +91,23 @@ func ValidateFilters(v *validator.Validator, f Filters) {
 	v.Check(validator.PermittedValue(f.Sort, f.SortSafeList...), "sort",
 		"invalid sort value")
 
+	// a cursor only makes sense with the sort it was created for
+	if f.Cursor != "" {
+		c, err := decodeCursor(f.Cursor)
+		v.Check(err == nil && c.Sort == f.Sort, "cursor", "invalid cursor value")
+	}
+
 }
 
 // Implement the sorting feature
 func (f Filters) sortColumn() string {
 	for _, safeValue := range f.SortSafeList {
 		if f.Sort == safeValue {
-			return strings.TrimPrefix(f.Sort, "-")
+			column := strings.TrimPrefix(f.Sort, "-")
+			if expression, found := f.SortExpressions[column]; found {
+				return expression
+			}
+			return column
 		}
 	}
 	// don't allow the operation to continue
@@ -81,7 +129,36 @@ func (f Filters) limit() int {
 }
 
 // calculate the offset so that we remember how many records have
-// been sent and how many remain to be sent
+// been sent and how many remain to be sent. With a cursor the records
+// already sent are left out by keysetCondition() instead
 func (f Filters) offset() int {
+	if f.Cursor != "" {
+		return 0
+	}
 	return (f.Page - 1) * f.PageSize
 }
+
+// The condition that skips every record up to and including the one in
+// the cursor. The sort value and id of the cursor are the arguments
+// $valueArg and $valueArg+1. Ties on the sort column are ordered by id in
+// the same direction, so a single row comparison does the job
+func (f Filters) keysetCondition(valueArg int) string {
+	operator := ">"
+	if f.sortDirection() == "DESC" {
+		operator = "<"
+	}
+	return fmt.Sprintf("(%s, id) %s ($%d, $%d)",
+		f.sortColumn(), operator, valueArg, valueArg+1)
+}
+
+// The metadata for a listing. In cursor mode there are no page numbers,
+// and COUNT(*) OVER() only counts what is left after the cursor, so we
+// only send back the page size and where to continue from
+func (f Filters) metadata(totalRecords int, nextCursor string) Metadata {
+	if f.Cursor != "" {
+		return Metadata{PageSize: f.PageSize, NextCursor: nextCursor}
+	}
+	metadata := calculateMetaData(totalRecords, f.Page, f.PageSize)
+	metadata.NextCursor = nextCursor
+	return metadata
+}
@@ -0,0 +1,93 @@
+// Filename: internal/data/filters_internal_test.go
+
+package data
+
+import (
+	"encoding/base64"
+	"testing"
+
+	"github.com/2016114132/qod/internal/validator"
+)
+
+func TestDecodeCursor(t *testing.T) {
+	valid := cursor{Sort: "-created_at", Value: "2025-03-14T00:00:00Z", ID: 42}
+
+	tests := []struct {
+		name   string
+		cursor string
+		want   cursor
+		valid  bool
+	}{
+		{"round trip", encodeCursor(valid), valid, true},
+		{"not base64", "not base64!", cursor{}, false},
+		{"padded base64", base64.URLEncoding.EncodeToString([]byte(`{"s":"id","v":"1","id":1}`)), cursor{}, false},
+		{"not json", base64.RawURLEncoding.EncodeToString([]byte("id=1")), cursor{}, false},
+		{"no id", base64.RawURLEncoding.EncodeToString([]byte(`{"s":"id","v":"1"}`)), cursor{}, false},
+		{"negative id", base64.RawURLEncoding.EncodeToString([]byte(`{"s":"id","v":"1","id":-1}`)), cursor{}, false},
+	}
+
+	for _, tt := range tests {
+		got, err := decodeCursor(tt.cursor)
+		if tt.valid && (err != nil || got != tt.want) {
+			t.Errorf("%s: expected: %+v, got: %+v (%v)", tt.name, tt.want, got, err)
+		}
+		if !tt.valid && err != errInvalidCursor {
+			t.Errorf("%s: expected: %q, got: %v", tt.name, errInvalidCursor, err)
+		}
+	}
+}
+
+// A cursor is only valid with the sort it was handed out for
+func TestValidateFiltersCursor(t *testing.T) {
+	tests := []struct {
+		name   string
+		sort   string
+		cursor string
+		want   string
+	}{
+		{"no cursor", "id", "", ""},
+		{"same sort", "-id", encodeCursor(cursor{Sort: "-id", Value: "7", ID: 7}), ""},
+		{"other direction", "id", encodeCursor(cursor{Sort: "-id", Value: "7", ID: 7}), "invalid cursor value"},
+		{"other column", "author", encodeCursor(cursor{Sort: "id", Value: "7", ID: 7}), "invalid cursor value"},
+		{"garbage", "id", "%%%", "invalid cursor value"},
+	}
+
+	for _, tt := range tests {
+		v := validator.New()
+		ValidateFilters(v, Filters{
+			Page:         1,
+			PageSize:     10,
+			Sort:         tt.sort,
+			SortSafeList: []string{"id", "author", "-id", "-author"},
+			Cursor:       tt.cursor,
+		})
+		if v.Errors["cursor"] != tt.want {
+			t.Errorf("%s: expected: %q, got: %q", tt.name, tt.want, v.Errors["cursor"])
+		}
+	}
+}
+
+func TestKeysetCondition(t *testing.T) {
+	tests := []struct {
+		sort string
+		want string
+	}{
+		{"id", "(id, id) > ($3, $4)"},
+		{"-id", "(id, id) < ($3, $4)"},
+		{"author", "(author, id) > ($3, $4)"},
+		{"-author", "(author, id) < ($3, $4)"},
+		{"-length", "(length(content), id) < ($3, $4)"},
+	}
+
+	for _, tt := range tests {
+		filters := Filters{
+			Sort:            tt.sort,
+			SortSafeList:    []string{"id", "author", "length", "-id", "-author", "-length"},
+			SortExpressions: map[string]string{"length": "length(content)"},
+		}
+		got := filters.keysetCondition(3)
+		if got != tt.want {
+			t.Errorf("%s: expected: %q, got: %q", tt.sort, tt.want, got)
+		}
+	}
+}
@@ -0,0 +1,78 @@
+package data
+
+import (
+	"context"
+	"database/sql"
+	"time"
+)
+
+// How long a whole import may take, from reading the file to saving its
+// quotes. The file is read before any transaction starts, but saving up
+// to 10,000 quotes needs more than the usual 3 seconds
+const ImportTimeout = 2 * time.Minute
+
+// How many quotes are saved in one transaction. The quote events of
+// everyone else wait for a transaction that is open (see
+// QuoteEventModel.GetAfter()), so a large import is saved a batch at a
+// time rather than in one long transaction
+const ImportBatchSize = 100
+
+// How long the transaction of one batch may take
+const importBatchTimeout = 30 * time.Second
+
+// A batch of a bulk import of quotes. Every quote of the batch goes into
+// the same transaction, so the batch is saved with Commit() or not at all
+type QuoteImport struct {
+	ctx    context.Context
+	cancel context.CancelFunc
+	tx     *sql.Tx
+}
+
+// Start a batch of a bulk import. The caller must call either Commit()
+// or Rollback() when it is done
+func (c QuoteModel) BeginImport() (*QuoteImport, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), importBatchTimeout)
+
+	tx, err := c.DB.BeginTx(ctx, nil)
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+	// the same lock as InsertUnique(), the batch is checked for
+	// duplicates as well
+	err = lockDuplicateCheck(ctx, tx)
+	if err != nil {
+		tx.Rollback()
+		cancel()
+		return nil, err
+	}
+
+	return &QuoteImport{ctx: ctx, cancel: cancel, tx: tx}, nil
+}
+
+// Add a quote to the batch
+func (qi *QuoteImport) Insert(quote *Quote) error {
+	return insertQuote(qi.ctx, qi.tx, quote)
+}
+
+// Get the quote that the content would duplicate, including the quotes
+// inserted earlier in this batch and the batches before it
+func (qi *QuoteImport) FindDuplicate(content string) (*Quote, error) {
+	return findDuplicate(qi.ctx, qi.tx, content)
+}
+
+// Save every quote of the batch
+func (qi *QuoteImport) Commit() error {
+	defer qi.cancel()
+	return qi.tx.Commit()
+}
+
+// Throw the batch away. Calling it after Commit() does nothing
+func (qi *QuoteImport) Rollback() error {
+	defer qi.cancel()
+	err := qi.tx.Rollback()
+	if err == sql.ErrTxDone {
+		return nil
+	}
+	return err
+}
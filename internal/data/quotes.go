@@ -4,13 +4,109 @@ import (
 	"context"
 	"database/sql"
 	"errors"
+	"math/rand/v2"
 
 	"fmt"
 	"time"
 
 	"github.com/2016114132/qod/internal/validator"
+	"github.com/lib/pq"
 )
 
+// The search terms that the quote listings can be narrowed down with
+type QuoteFilters struct {
+	Content       string
+	Author        string
+	Tags          []string
+	MatchAllTags  bool       // quotes must have all of the tags, not just one
+	AuthorID      int64      // 0 means any author
+	CreatedAfter  *time.Time // created at or after this time
+	CreatedBefore *time.Time // created before this time
+	AuthorExact   string     // the author must be exactly this
+	MinLength     int        // in characters, 0 means no minimum
+	MaxLength     int        // in characters, 0 means no maximum
+	Fuzzy         bool       // match content and author by trigrams, typos and all
+}
+
+// The arguments for quoteFilterClause, in the order it expects them
+func (qf QuoteFilters) args() []any {
+	// a nil slice would be sent as NULL instead of an empty array
+	tags := qf.Tags
+	if tags == nil {
+		tags = []string{}
+	}
+	return []any{qf.Content, qf.Author, pq.Array(tags), qf.MatchAllTags, qf.AuthorID,
+		qf.CreatedAfter, qf.CreatedBefore, qf.AuthorExact, qf.MinLength, qf.MaxLength, qf.Fuzzy}
+}
+
+// The search conditions shared by the queries that filter quotes. The
+// arguments from QuoteFilters.args() are always the first ones ($1-$11).
+// Quotes in the trash never show up in the listings. A fuzzy search
+// ($11) looks for words that are similar to the search terms (<% is
+// pg_trgm's word similarity) instead of the same words
+const quoteFilterClause = `
+        deleted_at IS NULL
+        AND ($1 = ''
+             OR (NOT $11 AND to_tsvector('simple', content) @@
+                 plainto_tsquery('simple', $1))
+             OR ($11 AND $1 <% content))
+        AND ($2 = ''
+             OR (NOT $11 AND to_tsvector('simple', author) @@
+                 plainto_tsquery('simple', $2))
+             OR ($11 AND $2 <% author))
+        AND (cardinality($3::text[]) = 0
+             OR (NOT $4 AND EXISTS (
+                   SELECT 1 FROM quotes_tags
+                   INNER JOIN tags ON tags.id = quotes_tags.tag_id
+                   WHERE quotes_tags.quote_id = quotes.id
+                   AND tags.name = ANY($3)))
+             OR ($4 AND (
+                   SELECT COUNT(*) FROM quotes_tags
+                   INNER JOIN tags ON tags.id = quotes_tags.tag_id
+                   WHERE quotes_tags.quote_id = quotes.id
+                   AND tags.name = ANY($3)) = cardinality($3::text[])))
+        AND (author_id = $5 OR $5 = 0)
+        AND ($6::timestamptz IS NULL OR created_at >= $6)
+        AND ($7::timestamptz IS NULL OR created_at < $7)
+        AND (author = $8 OR $8 = '')
+        AND (char_length(content) >= $9 OR $9 = 0)
+        AND (char_length(content) <= $10 OR $10 = 0)`
+
+// The number of arguments used by quoteFilterClause. The queries number
+// their own arguments after these
+const quoteFilterArgs = 11
+
+// The sort values of the quote listing that are not plain columns. The
+// relevance is how well the quote matches the content search ($1), or
+// how similar it is for a fuzzy search ($11)
+var quoteSortExpressions = map[string]string{
+	"length": "char_length(content)",
+	"relevance": `CASE WHEN $11 THEN word_similarity($1, content)
+                      ELSE ts_rank(to_tsvector('simple', content), plainto_tsquery('simple', $1)) END`,
+}
+
+// The columns that we read for every quote. scanTargets() gives the
+// matching destinations for Scan()
+const quoteColumns = `quotes.id, quotes.created_at, quotes.updated_at, quotes.content,
+               quotes.author, quotes.author_id, quotes.created_by,
+               quotes.deleted_at, quotes.version,` + quoteTagsColumn
+
+// Where Scan() should put each of the quoteColumns
+func (quote *Quote) scanTargets() []any {
+	return []any{
+		&quote.ID,
+		&quote.CreatedAt,
+		&quote.UpdatedAt,
+		&quote.Content,
+		&quote.Author,
+		&quote.AuthorID,
+		&quote.CreatedBy,
+		&quote.DeletedAt,
+		&quote.Version,
+		pq.Array(&quote.Tags),
+	}
+}
+
 // A QuoteModel expects a connection pool
 type QuoteModel struct {
 	DB *sql.DB
@@ -19,11 +115,16 @@ type QuoteModel struct {
 // make our JSON keys be displayed in all lowercase
 // "-" means don't show this field
 type Quote struct {
-	ID        int64     `json:"id"`
-	Content   string    `json:"content"`
-	Author    string    `json:"author"`
-	CreatedAt time.Time `json:"-"`
-	Version   int32     `json:"version"`
+	ID        int64      `json:"id"`
+	Content   string     `json:"content"`
+	Author    string     `json:"author"`
+	AuthorID  *int64     `json:"author_id"`
+	CreatedBy *int64     `json:"created_by"`           // the user who added the quote
+	DeletedAt *time.Time `json:"deleted_at,omitempty"` // when it went to the trash
+	Tags      []string   `json:"tags,omitempty"`
+	CreatedAt time.Time  `json:"-"`
+	UpdatedAt time.Time  `json:"-"`
+	Version   int32      `json:"version"`
 }
 
 func ValidateQuote(v *validator.Validator, quote *Quote) {
@@ -35,31 +136,74 @@ func ValidateQuote(v *validator.Validator, quote *Quote) {
 	v.Check(len(quote.Content) <= 100, "content", "must not be more than 100 bytes long")
 	// check if the Author field is empty
 	v.Check(len(quote.Author) <= 25, "author", "must not bem more than 25 bytes long")
+	// check the tags (they are optional)
+	v.Check(len(quote.Tags) <= 10, "tags", "must not contain more than 10 tags")
+	v.Check(validator.Unique(quote.Tags), "tags", "must not contain duplicate values")
+	for _, tag := range quote.Tags {
+		v.Check(tag != "", "tags", "must not contain empty values")
+		v.Check(len(tag) <= 30, "tags", "must not contain tags more than 30 bytes long")
+	}
 }
 
 // Insert a new row in the quotes table
 // Expects a pointer to the actual comment
 func (c QuoteModel) Insert(quote *Quote) error {
-	// the SQL query to be executed against the database table
-	query := `
-        INSERT INTO quotes (content, author)
-        VALUES ($1, $2)
-        RETURNING id, created_at, version
-        `
-	// the actual values to replace $1, and $2
-	args := []any{quote.Content, quote.Author}
 	// Create a context with a 3-second timeout. No database
 	// operation should take more than 3 seconds or we will quit it
 	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
 	defer cancel()
+
+	// The quote, its author and its tags are saved together or not at all
+	tx, err := c.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	// does nothing if the transaction was committed
+	defer tx.Rollback()
+
+	err = insertQuote(ctx, tx, quote)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// Insert a quote along with its author, its tags and the first version
+// in its history. This runs inside the transaction of the caller
+func insertQuote(ctx context.Context, tx *sql.Tx, quote *Quote) error {
+	// the SQL query to be executed against the database table
+	query := `
+        INSERT INTO quotes (content, author, author_id, created_by)
+        VALUES ($1, $2, $3, $4)
+        RETURNING id, created_at, updated_at, version
+        `
+	err := linkQuoteAuthor(ctx, tx, quote)
+	if err != nil {
+		return err
+	}
+	// the actual values to replace $1, $2, $3 and $4
+	args := []any{quote.Content, quote.Author, quote.AuthorID, quote.CreatedBy}
+
 	// execute the query against the quotes database table. We ask for the the
 	// id, created_at, and version to be sent back to us which we will use
 	// to update the quotes struct later on
-	return c.DB.QueryRowContext(ctx, query, args...).Scan(
+	err = tx.QueryRowContext(ctx, query, args...).Scan(
 		&quote.ID,
 		&quote.CreatedAt,
+		&quote.UpdatedAt,
 		&quote.Version)
+	if err != nil {
+		return err
+	}
+
+	err = setQuoteTags(ctx, tx, quote.ID, quote.Tags)
+	if err != nil {
+		return err
+	}
 
+	// the first version in the history of the quote
+	return insertQuoteRevision(ctx, tx, quote, quote.CreatedBy)
 }
 
 // Get a specific Comment from the comments table
@@ -70,9 +214,9 @@ func (c QuoteModel) Get(id int64) (*Quote, error) {
 	}
 	// the SQL query to be executed against the database table
 	query := `
-        SELECT id, created_at, content, author, version
+        SELECT ` + quoteColumns + `
         FROM quotes
-        WHERE id = $1
+        WHERE id = $1 AND deleted_at IS NULL
       `
 	// declare a variable of type Comment to store the returned comment
 	var quote Quote
@@ -81,13 +225,7 @@ func (c QuoteModel) Get(id int64) (*Quote, error) {
 	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
 	defer cancel()
 
-	err := c.DB.QueryRowContext(ctx, query, id).Scan(
-		&quote.ID,
-		&quote.CreatedAt,
-		&quote.Content,
-		&quote.Author,
-		&quote.Version,
-	)
+	err := c.DB.QueryRowContext(ctx, query, id).Scan(quote.scanTargets()...)
 	// check for which type of error
 	if err != nil {
 		switch {
@@ -100,26 +238,131 @@ func (c QuoteModel) Get(id int64) (*Quote, error) {
 	return &quote, nil
 }
 
-// Update a specific Comment from the comments table
-func (c QuoteModel) Update(quote *Quote) error {
+// Get many quotes at once, by id. The ids that have no quote (or whose
+// quote is in the trash) are left out of the map
+func (c QuoteModel) GetMany(ids []int64) (map[int64]*Quote, error) {
+	query := `
+        SELECT ` + quoteColumns + `
+        FROM quotes
+        WHERE id = ANY($1) AND deleted_at IS NULL
+      `
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	rows, err := c.DB.QueryContext(ctx, query, pq.Array(ids))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	quotes := make(map[int64]*Quote, len(ids))
+	for rows.Next() {
+		var quote Quote
+		err := rows.Scan(quote.scanTargets()...)
+		if err != nil {
+			return nil, err
+		}
+		quotes[quote.ID] = &quote
+	}
+	err = rows.Err()
+	if err != nil {
+		return nil, err
+	}
+
+	return quotes, nil
+}
+
+// Who is changing a quote. Only the user who added a quote may change
+// it, move it to the trash or take it back out, unless they are a
+// moderator. The rule is part of the UPDATE, so the quote can't change
+// hands between checking it and changing the quote
+type QuoteEditor struct {
+	ID        int64
+	Moderator bool
+}
+
+// Why an UPDATE of a quote didn't match its row: the quote is gone (or
+// not in the trash, for deleted), it isn't the editor's, or somebody
+// changed it in the meantime
+func quoteNotChanged(ctx context.Context, db rowQuerier, id int64, deleted bool, editor QuoteEditor) error {
+	query := `
+        SELECT created_by
+        FROM quotes
+        WHERE id = $1 AND (deleted_at IS NOT NULL) = $2
+      `
+	var createdBy *int64
+	err := db.QueryRowContext(ctx, query, id, deleted).Scan(&createdBy)
+	switch {
+	case errors.Is(err, sql.ErrNoRows):
+		return ErrRecordNotFound
+	case err != nil:
+		return err
+	case !editor.Moderator && (createdBy == nil || *createdBy != editor.ID):
+		return ErrNotPermitted
+	}
+	return ErrEditConflict
+}
+
+// Update a specific Comment from the comments table. Just like
+// UserModel.Update() the update only happens if the version is still the
+// one we read, otherwise someone else changed the quote in the meantime.
+// The new version is saved in the quote's history along with its editor
+func (c QuoteModel) Update(quote *Quote, editor QuoteEditor) error {
 	// The SQL query to be executed against the database table
 	// Every time we make an update, we increment the version number
 	query := `
         UPDATE quotes
-        SET content = $1, author = $2, version = version + 1
-        WHERE id = $3
-        RETURNING version
+        SET content = $1, author = $2, author_id = $3,
+            updated_at = NOW(), version = version + 1
+        WHERE id = $4 AND version = $5 AND deleted_at IS NULL
+        AND (created_by = $6 OR $7)
+        RETURNING updated_at, version
 `
-	args := []any{quote.Content, quote.Author, quote.ID}
 	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
 	defer cancel()
 
-	return c.DB.QueryRowContext(ctx, query, args...).Scan(&quote.Version)
+	tx, err := c.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	err = linkQuoteAuthor(ctx, tx, quote)
+	if err != nil {
+		return err
+	}
+	args := []any{quote.Content, quote.Author, quote.AuthorID, quote.ID, quote.Version,
+		editor.ID, editor.Moderator}
+
+	err = tx.QueryRowContext(ctx, query, args...).Scan(&quote.UpdatedAt, &quote.Version)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return quoteNotChanged(ctx, tx, quote.ID, false, editor)
+		default:
+			return err
+		}
+	}
+
+	err = setQuoteTags(ctx, tx, quote.ID, quote.Tags)
+	if err != nil {
+		return err
+	}
+
+	err = insertQuoteRevision(ctx, tx, quote, &editor.ID)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
 
 }
 
-// Delete a specific Comment from the comments table
-func (c QuoteModel) Delete(id int64) error {
+// Delete a specific Comment from the comments table. The quote is only
+// moved to the trash, it can be restored until it is purged. With a
+// version the quote is only deleted if it is still at that version,
+// otherwise we have an edit conflict. Version 0 deletes any version
+func (c QuoteModel) Delete(id int64, version int32, editor QuoteEditor) error {
 
 	// check if the id is valid
 	if id < 1 {
@@ -127,8 +370,11 @@ func (c QuoteModel) Delete(id int64) error {
 	}
 	// the SQL query to be executed against the database table
 	query := `
-        DELETE FROM quotes
-        WHERE id = $1
+        UPDATE quotes
+        SET deleted_at = NOW()
+        WHERE id = $1 AND deleted_at IS NULL
+        AND (version = $2 OR $2 = 0)
+        AND (created_by = $3 OR $4)
       `
 	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
 	defer cancel()
@@ -136,7 +382,7 @@ func (c QuoteModel) Delete(id int64) error {
 	// ExecContext does not return any rows unlike QueryRowContext.
 	// It only returns  information about the the query execution
 	// such as how many rows were affected
-	result, err := c.DB.ExecContext(ctx, query, id)
+	result, err := c.DB.ExecContext(ctx, query, id, version, editor.ID, editor.Moderator)
 	if err != nil {
 		return err
 	}
@@ -145,35 +391,53 @@ func (c QuoteModel) Delete(id int64) error {
 	if err != nil {
 		return err
 	}
-	// Probably a wrong id was provided or the client is trying to
-	// delete an already deleted quote
+	// Probably a wrong id was provided, the client is trying to
+	// delete an already deleted quote or one that isn't theirs. With a
+	// version, someone else may have changed it since it was read
 	if rowsAffected == 0 {
-		return ErrRecordNotFound
+		return quoteNotChanged(ctx, c.DB, id, false, editor)
 	}
 
 	return nil
 
 }
 
-// Get all comments
-func (c QuoteModel) GetAll(content string, author string, filters Filters) ([]*Quote, Metadata, error) {
+// Get all comments. Besides page numbers, the listing can be read with
+// a cursor (filters.Cursor). Every page comes with a next_cursor in the
+// metadata, as long as there are more quotes after it
+func (c QuoteModel) GetAll(quoteFilters QuoteFilters, filters Filters) ([]*Quote, Metadata, error) {
 
-	// the SQL query to be executed against the database table
+	filters.SortExpressions = quoteSortExpressions
+
+	args := quoteFilters.args()
+	// in cursor mode we skip the quotes that were already sent
+	keyset := "TRUE"
+	if filters.Cursor != "" {
+		after, err := decodeCursor(filters.Cursor)
+		if err != nil {
+			return nil, Metadata{}, err
+		}
+		keyset = filters.keysetCondition(len(args) + 1)
+		args = append(args, after.Value, after.ID)
+	}
+
+	// We ask for one quote more than the page size. If we get it, there
+	// is a next page and the last quote of this page is its cursor
 	query := fmt.Sprintf(`
-        SELECT COUNT(*) OVER(), id, created_at, content, author, version
+        SELECT COUNT(*) OVER(), %[1]s::text, %[2]s
         FROM quotes
-        WHERE (to_tsvector('simple', content) @@
-              plainto_tsquery('simple', $1) OR $1 = '') 
-        AND (to_tsvector('simple', author) @@ 
-             plainto_tsquery('simple', $2) OR $2 = '') 
-        ORDER BY %s %s, id ASC 
-        LIMIT $3 OFFSET $4`, filters.sortColumn(), filters.sortDirection())
+        WHERE %[3]s AND %[4]s
+        ORDER BY %[1]s %[5]s, id %[5]s
+        LIMIT $%[6]d OFFSET $%[7]d`, filters.sortColumn(), quoteColumns,
+		quoteFilterClause, keyset, filters.sortDirection(),
+		len(args)+1, len(args)+2)
+	args = append(args, filters.limit()+1, filters.offset())
 
 	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
 	defer cancel()
 
 	// QueryContext returns multiple rows.
-	rows, err := c.DB.QueryContext(ctx, query, content, author, filters.limit(), filters.offset())
+	rows, err := c.DB.QueryContext(ctx, query, args...)
 
 	if err != nil {
 		return nil, Metadata{}, err
@@ -184,23 +448,20 @@ func (c QuoteModel) GetAll(content string, author string, filters Filters) ([]*Q
 	totalRecords := 0
 	// we will store the address of each comment in our slice
 	quotes := []*Quote{}
+	// the sort column value of each quote, for the cursor
+	sortValues := []string{}
 
 	// process each row that is in rows
 	for rows.Next() {
 		var quote Quote
-		err := rows.Scan(
-			&totalRecords,
-			&quote.ID,
-			&quote.CreatedAt,
-			&quote.Content,
-			&quote.Author,
-			&quote.Version,
-		)
+		var sortValue string
+		err := rows.Scan(append([]any{&totalRecords, &sortValue}, quote.scanTargets()...)...)
 		if err != nil {
 			return nil, Metadata{}, err
 		}
 		// add the row to our slice
 		quotes = append(quotes, &quote)
+		sortValues = append(sortValues, sortValue)
 	} // end of for loop
 	// after we exit the loop we need to check if it generated any errors
 	err = rows.Err()
@@ -208,9 +469,80 @@ func (c QuoteModel) GetAll(content string, author string, filters Filters) ([]*Q
 		return nil, Metadata{}, err
 	}
 
+	nextCursor := ""
+	if len(quotes) > filters.limit() {
+		quotes = quotes[:filters.limit()]
+		last := len(quotes) - 1
+		nextCursor = encodeCursor(cursor{
+			Sort:  filters.Sort,
+			Value: sortValues[last],
+			ID:    quotes[last].ID,
+		})
+	}
+
 	// Create the metadata
-	metadata := calculateMetaData(totalRecords, filters.Page, filters.PageSize)
+	metadata := filters.metadata(totalRecords, nextCursor)
 
 	return quotes, metadata, nil
 
 }
+
+// Get up to count random quotes that match the search terms. ORDER BY
+// random() would read the whole table, so instead we pick random ids
+// between the smallest and largest matching id and take the first
+// matching quote at or after each of them. Using the same rng (seed)
+// against the same data gives back the same quotes
+func (c QuoteModel) GetRandom(quoteFilters QuoteFilters, count int, rng *rand.Rand) ([]*Quote, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	// the range of ids that we can pick from
+	query := fmt.Sprintf(`
+        SELECT COALESCE(MIN(id), 0), COALESCE(MAX(id), 0)
+        FROM quotes
+        WHERE %s`, quoteFilterClause)
+
+	var minID, maxID int64
+	err := c.DB.QueryRowContext(ctx, query, quoteFilters.args()...).Scan(&minID, &maxID)
+	if err != nil {
+		return nil, err
+	}
+
+	quotes := []*Quote{}
+	// no quote matched
+	if maxID == 0 {
+		return quotes, nil
+	}
+
+	query = fmt.Sprintf(`
+        SELECT %s
+        FROM quotes
+        WHERE id >= $%d AND %s
+        ORDER BY id
+        LIMIT 1`, quoteColumns, quoteFilterArgs+1, quoteFilterClause)
+
+	// picks can land on a quote we already have, so we allow a few extra
+	// attempts but we don't keep trying forever when few quotes match
+	seen := make(map[int64]bool)
+	for attempt := 0; len(quotes) < count && attempt < count*5; attempt++ {
+		id := minID + rng.Int64N(maxID-minID+1)
+
+		var quote Quote
+		args := append(quoteFilters.args(), id)
+		err := c.DB.QueryRowContext(ctx, query, args...).Scan(quote.scanTargets()...)
+		// the quotes after our pick could have been deleted meanwhile
+		if errors.Is(err, sql.ErrNoRows) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		if seen[quote.ID] {
+			continue
+		}
+		seen[quote.ID] = true
+		quotes = append(quotes, &quote)
+	}
+
+	return quotes, nil
+}
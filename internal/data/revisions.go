@@ -0,0 +1,125 @@
+package data
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// A copy of a quote as it was at one of its versions. EditorID is the
+// user who made that version
+type QuoteRevision struct {
+	ID        int64     `json:"id"`
+	QuoteID   int64     `json:"quote_id"`
+	Version   int32     `json:"version"`
+	Content   string    `json:"content"`
+	Author    string    `json:"author"`
+	EditorID  *int64    `json:"editor_id"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// Save the current version of the quote in its history. This runs inside
+// the transaction of the quote insert/update so that every version that
+// makes it to the quotes table is also in quote_revisions
+func insertQuoteRevision(ctx context.Context, tx *sql.Tx, quote *Quote, editorID *int64) error {
+	query := `
+        INSERT INTO quote_revisions (quote_id, version, content, author, editor_id)
+        VALUES ($1, $2, $3, $4, $5)
+        `
+	args := []any{quote.ID, quote.Version, quote.Content, quote.Author, editorID}
+
+	_, err := tx.ExecContext(ctx, query, args...)
+	return err
+}
+
+// Setup our model
+type RevisionModel struct {
+	DB *sql.DB
+}
+
+// Get a specific version of a quote
+func (m RevisionModel) Get(quoteID int64, version int32) (*QuoteRevision, error) {
+	if quoteID < 1 || version < 1 {
+		return nil, ErrRecordNotFound
+	}
+	query := `
+        SELECT id, quote_id, version, content, author, editor_id, created_at
+        FROM quote_revisions
+        WHERE quote_id = $1 AND version = $2
+      `
+	var revision QuoteRevision
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	err := m.DB.QueryRowContext(ctx, query, quoteID, version).Scan(
+		&revision.ID,
+		&revision.QuoteID,
+		&revision.Version,
+		&revision.Content,
+		&revision.Author,
+		&revision.EditorID,
+		&revision.CreatedAt,
+	)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrRecordNotFound
+		default:
+			return nil, err
+		}
+	}
+
+	return &revision, nil
+}
+
+// Get the history of a quote
+func (m RevisionModel) GetAllForQuote(quoteID int64, filters Filters) ([]*QuoteRevision, Metadata, error) {
+	query := fmt.Sprintf(`
+        SELECT COUNT(*) OVER(), id, quote_id, version, content, author,
+               editor_id, created_at
+        FROM quote_revisions
+        WHERE quote_id = $1
+        ORDER BY %s %s
+        LIMIT $2 OFFSET $3`, filters.sortColumn(), filters.sortDirection())
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query, quoteID, filters.limit(), filters.offset())
+	if err != nil {
+		return nil, Metadata{}, err
+	}
+	defer rows.Close()
+
+	totalRecords := 0
+	revisions := []*QuoteRevision{}
+
+	for rows.Next() {
+		var revision QuoteRevision
+		err := rows.Scan(
+			&totalRecords,
+			&revision.ID,
+			&revision.QuoteID,
+			&revision.Version,
+			&revision.Content,
+			&revision.Author,
+			&revision.EditorID,
+			&revision.CreatedAt,
+		)
+		if err != nil {
+			return nil, Metadata{}, err
+		}
+		revisions = append(revisions, &revision)
+	}
+	err = rows.Err()
+	if err != nil {
+		return nil, Metadata{}, err
+	}
+
+	metadata := calculateMetaData(totalRecords, filters.Page, filters.PageSize)
+
+	return revisions, metadata, nil
+}
@@ -0,0 +1,359 @@
+package data
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"time"
+
+	"github.com/lib/pq"
+
+	"github.com/2016114132/qod/internal/validator"
+)
+
+// A quote that an editor has pinned to a specific date. The date is
+// kept in the YYYY-MM-DD format since it is a calendar day, not a time
+type ScheduledQuote struct {
+	ID        int64     `json:"id"`
+	Date      string    `json:"date"`
+	QuoteID   int64     `json:"quote_id"`
+	Quote     *Quote    `json:"quote,omitempty"`
+	CreatedAt time.Time `json:"-"`
+	Version   int32     `json:"version"`
+	// picked by Pick() (or GetForDays()) rather than by an editor
+	Automatic bool `json:"automatic"`
+}
+
+// Specify a custom error for when a date already has a quote
+var ErrDuplicateDate = errors.New("duplicate date")
+
+func ValidateScheduledQuote(v *validator.Validator, scheduled *ScheduledQuote) {
+	v.Check(scheduled.QuoteID > 0, "quote_id", "must be provided")
+	v.Check(scheduled.Date != "", "date", "must be provided")
+	_, err := time.Parse("2006-01-02", scheduled.Date)
+	v.Check(err == nil, "date", "must be a date in the format YYYY-MM-DD")
+}
+
+// A ScheduleModel expects a connection pool
+type ScheduleModel struct {
+	DB *sql.DB
+}
+
+// Pin a quote to a date. Only one quote can be pinned to a date, but
+// the editors may take over a date that has an automatic pick
+func (s ScheduleModel) Insert(scheduled *ScheduledQuote) error {
+	query := `
+        INSERT INTO quote_schedule (scheduled_for, quote_id)
+        VALUES ($1, $2)
+        ON CONFLICT (scheduled_for) DO UPDATE
+        SET quote_id = EXCLUDED.quote_id, automatic = false,
+            version = quote_schedule.version + 1
+        WHERE quote_schedule.automatic
+        RETURNING id, created_at, version
+        `
+	args := []any{scheduled.Date, scheduled.QuoteID}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	err := s.DB.QueryRowContext(ctx, query, args...).Scan(
+		&scheduled.ID,
+		&scheduled.CreatedAt,
+		&scheduled.Version)
+	if err != nil {
+		switch {
+		// an editor already picked a quote for the date
+		case errors.Is(err, sql.ErrNoRows):
+			return ErrDuplicateDate
+		default:
+			return err
+		}
+	}
+
+	return nil
+}
+
+// The number that picks the automatic quote of a calendar day. We hash
+// the day (YYYY-MM-DD) so that every server, after every restart, makes
+// the same pick for that day
+func daySeed(day time.Time) int64 {
+	hash := fnv.New64a()
+	hash.Write([]byte(day.Format("2006-01-02")))
+	// drop the top bit so the value fits in a PostgreSQL bigint
+	return int64(hash.Sum64() >> 1)
+}
+
+// The automatic picks of the days ($1, as YYYY-MM-DD) with their seeds
+// ($2). The seed of a day (daySeed()) is wrapped around the number of
+// quotes, so it always lands on an existing quote. There are no picks
+// when there are no quotes at all
+const dayPicks = `
+        WITH live AS (
+            SELECT id, row_number() OVER (ORDER BY id) - 1 AS position,
+                   COUNT(*) OVER () AS total
+            FROM quotes
+            WHERE deleted_at IS NULL
+        ), picks AS (
+            SELECT days.date, live.id AS quote_id
+            FROM unnest($1::text[], $2::bigint[]) AS days(date, seed)
+            INNER JOIN live ON live.position = days.seed % live.total
+        )`
+
+// The dates and seeds of the days, the arguments of dayPicks
+func dayPickArgs(days []time.Time) []any {
+	dates := make([]string, len(days))
+	seeds := make([]int64, len(days))
+	for i, day := range days {
+		dates[i] = day.Format("2006-01-02")
+		seeds[i] = daySeed(day)
+	}
+	return []any{pq.Array(dates), pq.Array(seeds)}
+}
+
+// Save the automatic pick of the day, if nobody has picked a quote for
+// it. Once saved the pick stays, however many quotes are added or
+// removed later, unless its quote goes to the trash. Only our background
+// job calls it, the requests just read the picks with GetForDays()
+func (s ScheduleModel) Pick(day time.Time) error {
+	query := dayPicks + `
+        INSERT INTO quote_schedule (scheduled_for, quote_id, automatic)
+        SELECT picks.date::date, picks.quote_id, true
+        FROM picks
+        ON CONFLICT (scheduled_for) DO UPDATE
+        SET quote_id = EXCLUDED.quote_id, automatic = true,
+            version = quote_schedule.version + 1
+        WHERE EXISTS (SELECT 1 FROM quotes
+                      WHERE quotes.id = quote_schedule.quote_id
+                      AND quotes.deleted_at IS NOT NULL)
+      `
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	_, err := s.DB.ExecContext(ctx, query, dayPickArgs([]time.Time{day})...)
+	return err
+}
+
+// Get the quote of each of the days, by day (YYYY-MM-DD): the one in the
+// schedule, or else the automatic pick worked out the same way as
+// Pick() but not saved, so that reading never writes. A scheduled quote
+// that went to the trash gives way to the automatic pick. The ID of a
+// pick that is not saved is 0, and a day is missing when there are no
+// quotes at all
+func (s ScheduleModel) GetForDays(days []time.Time) (map[string]*ScheduledQuote, error) {
+	query := dayPicks + `
+        SELECT days.date, COALESCE(quote_schedule.id, 0),
+               COALESCE(quote_schedule.version, 0),
+               COALESCE(quote_schedule.automatic, true), ` + quoteColumns + `
+        FROM unnest($1::text[]) AS days(date)
+        LEFT JOIN quote_schedule
+            ON quote_schedule.scheduled_for = days.date::date
+            AND EXISTS (SELECT 1 FROM quotes AS scheduled
+                        WHERE scheduled.id = quote_schedule.quote_id
+                        AND scheduled.deleted_at IS NULL)
+        LEFT JOIN picks ON picks.date = days.date
+        INNER JOIN quotes
+            ON quotes.id = COALESCE(quote_schedule.quote_id, picks.quote_id)
+      `
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	rows, err := s.DB.QueryContext(ctx, query, dayPickArgs(days)...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	schedule := make(map[string]*ScheduledQuote, len(days))
+	for rows.Next() {
+		var scheduled ScheduledQuote
+		var quote Quote
+		targets := []any{&scheduled.Date, &scheduled.ID, &scheduled.Version,
+			&scheduled.Automatic}
+		err := rows.Scan(append(targets, quote.scanTargets()...)...)
+		if err != nil {
+			return nil, err
+		}
+		scheduled.QuoteID = quote.ID
+		scheduled.Quote = &quote
+		schedule[scheduled.Date] = &scheduled
+	}
+	err = rows.Err()
+	if err != nil {
+		return nil, err
+	}
+
+	return schedule, nil
+}
+
+// Get the scheduled quote (and the quote itself) for a date. A quote that
+// went to the trash is not shown even if it was scheduled
+func (s ScheduleModel) GetForDate(date string) (*ScheduledQuote, error) {
+	query := `
+        SELECT quote_schedule.id, quote_schedule.created_at,
+               to_char(quote_schedule.scheduled_for, 'YYYY-MM-DD'),
+               quote_schedule.version, quote_schedule.automatic, ` + quoteColumns + `
+        FROM quote_schedule
+        INNER JOIN quotes ON quotes.id = quote_schedule.quote_id
+        WHERE quote_schedule.scheduled_for = $1
+        AND quotes.deleted_at IS NULL
+      `
+	var scheduled ScheduledQuote
+	var quote Quote
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	targets := []any{&scheduled.ID, &scheduled.CreatedAt, &scheduled.Date, &scheduled.Version,
+		&scheduled.Automatic}
+	err := s.DB.QueryRowContext(ctx, query, date).Scan(append(targets, quote.scanTargets()...)...)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrRecordNotFound
+		default:
+			return nil, err
+		}
+	}
+	scheduled.QuoteID = quote.ID
+	scheduled.Quote = &quote
+
+	return &scheduled, nil
+}
+
+// Get the quotes that the editors scheduled between two dates (both
+// included). An empty from or to means that side of the range is open.
+// Just like with GetForDate() the days whose quote went to the trash are
+// left out, and so are the automatic picks
+func (s ScheduleModel) GetAll(from string, to string, filters Filters) ([]*ScheduledQuote, Metadata, error) {
+	// the only thing we sort by is the date so we just need the direction
+	query := fmt.Sprintf(`
+        SELECT COUNT(*) OVER(), quote_schedule.id, quote_schedule.created_at,
+               to_char(quote_schedule.scheduled_for, 'YYYY-MM-DD'),
+               quote_schedule.version, quote_schedule.automatic, %s
+        FROM quote_schedule
+        INNER JOIN quotes ON quotes.id = quote_schedule.quote_id
+        WHERE quotes.deleted_at IS NULL
+        AND NOT quote_schedule.automatic
+        AND (quote_schedule.scheduled_for >= NULLIF($1, '')::date OR $1 = '')
+        AND (quote_schedule.scheduled_for <= NULLIF($2, '')::date OR $2 = '')
+        ORDER BY quote_schedule.scheduled_for %s
+        LIMIT $3 OFFSET $4`, quoteColumns, filters.sortDirection())
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	rows, err := s.DB.QueryContext(ctx, query, from, to, filters.limit(), filters.offset())
+	if err != nil {
+		return nil, Metadata{}, err
+	}
+	defer rows.Close()
+
+	totalRecords := 0
+	schedule := []*ScheduledQuote{}
+
+	for rows.Next() {
+		var scheduled ScheduledQuote
+		var quote Quote
+		targets := []any{&totalRecords, &scheduled.ID, &scheduled.CreatedAt,
+			&scheduled.Date, &scheduled.Version, &scheduled.Automatic}
+		err := rows.Scan(append(targets, quote.scanTargets()...)...)
+		if err != nil {
+			return nil, Metadata{}, err
+		}
+		scheduled.QuoteID = quote.ID
+		scheduled.Quote = &quote
+		schedule = append(schedule, &scheduled)
+	}
+	err = rows.Err()
+	if err != nil {
+		return nil, Metadata{}, err
+	}
+
+	metadata := calculateMetaData(totalRecords, filters.Page, filters.PageSize)
+
+	return schedule, metadata, nil
+}
+
+// Move a scheduled quote to another date or swap the quote for that date.
+// Just like UserModel.Update() we check the version to catch edit conflicts.
+// An automatic pick that an editor changes is theirs from then on, and
+// just like with Insert() the editors may take over a date that has one
+func (s ScheduleModel) Update(scheduled *ScheduledQuote) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	tx, err := s.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	// does nothing once the transaction is committed
+	defer tx.Rollback()
+
+	query := `
+        DELETE FROM quote_schedule
+        WHERE scheduled_for = $1 AND automatic AND id <> $2
+        `
+	_, err = tx.ExecContext(ctx, query, scheduled.Date, scheduled.ID)
+	if err != nil {
+		return err
+	}
+
+	query = `
+        UPDATE quote_schedule
+        SET scheduled_for = $1, quote_id = $2, automatic = false,
+            version = version + 1
+        WHERE id = $3 AND version = $4
+        RETURNING version
+        `
+	args := []any{scheduled.Date, scheduled.QuoteID, scheduled.ID, scheduled.Version}
+
+	err = tx.QueryRowContext(ctx, query, args...).Scan(&scheduled.Version)
+	if err != nil {
+		var pqError *pq.Error
+		switch {
+		case errors.As(err, &pqError) && pqError.Code.Name() == "unique_violation" &&
+			pqError.Constraint == "quote_schedule_scheduled_for_key":
+			return ErrDuplicateDate
+		case errors.Is(err, sql.ErrNoRows):
+			return ErrEditConflict
+		default:
+			return err
+		}
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return err
+	}
+	scheduled.Automatic = false
+
+	return nil
+}
+
+// Clear the date so that the automatic selection is used again. For
+// today that is saved again by our background job within the hour
+func (s ScheduleModel) Delete(date string) error {
+	query := `
+        DELETE FROM quote_schedule
+        WHERE scheduled_for = $1
+      `
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	result, err := s.DB.ExecContext(ctx, query, date)
+	if err != nil {
+		return err
+	}
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	// nothing was scheduled for that date
+	if rowsAffected == 0 {
+		return ErrRecordNotFound
+	}
+
+	return nil
+}
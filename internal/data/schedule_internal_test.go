@@ -0,0 +1,32 @@
+// Filename: internal/data/schedule_internal_test.go
+
+package data
+
+import (
+	"testing"
+	"time"
+)
+
+// The same day gives the same seed whatever the time of day, and every
+// day of the year gets its own seed
+func TestDaySeed(t *testing.T) {
+	day := time.Date(2025, time.March, 14, 0, 0, 0, 0, time.UTC)
+	later := time.Date(2025, time.March, 14, 23, 59, 59, 0, time.UTC)
+	if daySeed(day) != daySeed(later) {
+		t.Errorf("expected: %d, got: %d", daySeed(day), daySeed(later))
+	}
+
+	seen := make(map[int64]string)
+	start := time.Date(2025, time.January, 1, 0, 0, 0, 0, time.UTC)
+	for i := 0; i < 366; i++ {
+		date := start.AddDate(0, 0, i).Format("2006-01-02")
+		seed := daySeed(start.AddDate(0, 0, i))
+		if seed < 0 {
+			t.Errorf("%s: expected a seed of 0 or more, got: %d", date, seed)
+		}
+		if other, found := seen[seed]; found {
+			t.Errorf("%s: expected a seed of its own, got the one of %s", date, other)
+		}
+		seen[seed] = date
+	}
+}
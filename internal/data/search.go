@@ -0,0 +1,152 @@
+package data
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/2016114132/qod/internal/validator"
+)
+
+// The text search configurations that a search can use, along with the
+// stored tsvector column that was built with each of them
+var searchColumns = map[string]string{
+	"english": "search_english",
+	"simple":  "search_simple",
+}
+
+// The languages that can be searched in. english stems words ("loving"
+// finds "love"), simple matches words as they are
+var SearchLanguages = []string{"english", "simple"}
+
+// How the matches are marked in the snippets
+const searchHeadlineOptions = "StartSel=<mark>, StopSel=</mark>, MaxWords=20, MinWords=8, MaxFragments=2"
+
+// The content of a quote with the HTML special characters escaped. The
+// snippets are made from it, so that the <mark> tags are the only markup
+// in them and a quote containing <script> is shown as text
+const escapedContent = `replace(replace(replace(replace(quotes.content,
+                   '&', '&amp;'), '<', '&lt;'), '>', '&gt;'), '"', '&quot;')`
+
+// A quote that matched a search, how well it matched and the part of
+// its content with the matching words marked. The snippet is HTML: the
+// content is escaped and only the <mark> tags are left as they are
+type SearchResult struct {
+	Quote   *Quote  `json:"quote"`
+	Rank    float32 `json:"rank"`
+	Snippet string  `json:"snippet"`
+}
+
+// The query is what the user typed, so it must not be empty or huge
+func ValidateSearch(v *validator.Validator, query string, lang string) {
+	v.Check(query != "", "q", "must be provided")
+	v.Check(len(query) <= 200, "q", "must not be more than 200 bytes long")
+	v.Check(validator.PermittedValue(lang, SearchLanguages...), "lang",
+		"must be one of the supported languages")
+}
+
+// Split the prefix terms (a word ending in *, like lov*) off a web
+// search query. websearch_to_tsquery() has no prefixes, so they become
+// a to_tsquery() query of their own (lov:*) that must match as well.
+// A term that isn't a plain word, or is inside a quoted phrase, stays in
+// the web search query where the * is ignored
+func splitPrefixTerms(search string) (string, string) {
+	var rest, prefixes []string
+	inPhrase := false
+
+	for _, term := range strings.Fields(search) {
+		startsPhrase := strings.HasPrefix(term, `"`)
+		if inPhrase || startsPhrase {
+			// a phrase ends with the term that closes the quote
+			if strings.Count(term, `"`)%2 == 1 {
+				inPhrase = !inPhrase
+			}
+			rest = append(rest, term)
+			continue
+		}
+
+		word := strings.TrimRight(term, "*")
+		excluded := strings.HasPrefix(word, "-")
+		word = strings.TrimPrefix(word, "-")
+		plain := word != "" && strings.IndexFunc(word, func(r rune) bool {
+			return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+		}) == -1
+		if word == term || !plain {
+			rest = append(rest, term)
+			continue
+		}
+
+		if excluded {
+			prefixes = append(prefixes, "!"+word+":*")
+		} else {
+			prefixes = append(prefixes, word+":*")
+		}
+	}
+
+	return strings.Join(rest, " "), strings.Join(prefixes, " & ")
+}
+
+// Search the content and author of the quotes. The query uses the web
+// search syntax: "quoted phrases", -excluded words and OR, and a word
+// ending in * matches every word that starts with it (lov* finds love
+// and lovely). The prefixes must all match, whatever OR says. The best
+// matches come first
+func (c QuoteModel) Search(search string, lang string, filters Filters) ([]*SearchResult, Metadata, error) {
+	column, found := searchColumns[lang]
+	if !found {
+		return nil, Metadata{}, fmt.Errorf("unsupported search language %q", lang)
+	}
+	search, prefixes := splitPrefixTerms(search)
+
+	query := fmt.Sprintf(`
+        WITH search AS (
+            SELECT CASE
+                WHEN $6 = '' THEN websearch_to_tsquery($2::regconfig, $1)
+                WHEN $1 = '' THEN to_tsquery($2::regconfig, $6)
+                ELSE websearch_to_tsquery($2::regconfig, $1) && to_tsquery($2::regconfig, $6)
+            END AS search_query
+        )
+        SELECT COUNT(*) OVER(), %[1]s,
+               ts_rank(quotes.%[2]s, search_query) AS rank,
+               ts_headline($2::regconfig, %[3]s, search_query, $3)
+        FROM quotes, search
+        WHERE quotes.deleted_at IS NULL
+        AND quotes.%[2]s @@ search_query
+        ORDER BY rank DESC, quotes.id ASC
+        LIMIT $4 OFFSET $5`, quoteColumns, column, escapedContent)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	args := []any{search, lang, searchHeadlineOptions, filters.limit(), filters.offset(), prefixes}
+	rows, err := c.DB.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, Metadata{}, err
+	}
+	defer rows.Close()
+
+	totalRecords := 0
+	results := []*SearchResult{}
+
+	for rows.Next() {
+		var result SearchResult
+		result.Quote = &Quote{}
+		targets := append([]any{&totalRecords}, result.Quote.scanTargets()...)
+		targets = append(targets, &result.Rank, &result.Snippet)
+		err := rows.Scan(targets...)
+		if err != nil {
+			return nil, Metadata{}, err
+		}
+		results = append(results, &result)
+	}
+	err = rows.Err()
+	if err != nil {
+		return nil, Metadata{}, err
+	}
+
+	metadata := calculateMetaData(totalRecords, filters.Page, filters.PageSize)
+
+	return results, metadata, nil
+}
@@ -0,0 +1,28 @@
+// Filename: internal/data/search_internal_test.go
+
+package data
+
+import "testing"
+
+func TestSplitPrefixTerms(t *testing.T) {
+	tests := []struct {
+		search   string
+		rest     string
+		prefixes string
+	}{
+		{"love", "love", ""},
+		{"lov*", "", "lov:*"},
+		{"lov* -hat* courage", "courage", "lov:* & !hat:*"},
+		{`"lov* is" wis*`, `"lov* is"`, "wis:*"},
+		{`"all you need" lov* OR hope`, `"all you need" OR hope`, "lov:*"},
+		{"don't* *", "don't* *", ""},
+		{"café*", "", "café:*"},
+	}
+
+	for _, tt := range tests {
+		rest, prefixes := splitPrefixTerms(tt.search)
+		if rest != tt.rest || prefixes != tt.prefixes {
+			t.Errorf("%s: expected: %q %q, got: %q %q", tt.search, tt.rest, tt.prefixes, rest, prefixes)
+		}
+	}
+}
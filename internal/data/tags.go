@@ -0,0 +1,109 @@
+package data
+
+import (
+	"context"
+	"database/sql"
+	"strings"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// The tags of a quote, sorted by name. We use it as a column in every
+// query that returns quotes so that the tags always come along
+const quoteTagsColumn = `
+        ARRAY(SELECT tags.name FROM tags
+              INNER JOIN quotes_tags ON quotes_tags.tag_id = tags.id
+              WHERE quotes_tags.quote_id = quotes.id
+              ORDER BY tags.name)`
+
+// A tag along with how many quotes use it (for tag clouds)
+type Tag struct {
+	Name       string `json:"name"`
+	QuoteCount int    `json:"quote_count"`
+}
+
+// Tags are stored in lowercase without surrounding spaces so that
+// "Love", "love " and "love" are the same tag. Empty entries are
+// kept so that validation can complain about them
+func NormalizeTags(tags []string) []string {
+	normalized := make([]string, 0, len(tags))
+	for _, tag := range tags {
+		normalized = append(normalized, strings.ToLower(strings.TrimSpace(tag)))
+	}
+	return normalized
+}
+
+// Replace the tags of a quote. Tags we have not seen before are created.
+// This runs inside the transaction of the quote insert/update
+func setQuoteTags(ctx context.Context, tx *sql.Tx, quoteID int64, tags []string) error {
+	_, err := tx.ExecContext(ctx, `DELETE FROM quotes_tags WHERE quote_id = $1`, quoteID)
+	if err != nil {
+		return err
+	}
+	if len(tags) == 0 {
+		return nil
+	}
+
+	query := `
+        INSERT INTO tags (name)
+        SELECT unnest($1::text[])
+        ON CONFLICT (name) DO NOTHING
+        `
+	_, err = tx.ExecContext(ctx, query, pq.Array(tags))
+	if err != nil {
+		return err
+	}
+
+	query = `
+        INSERT INTO quotes_tags (quote_id, tag_id)
+        SELECT $1, tags.id FROM tags
+        WHERE tags.name = ANY($2)
+        `
+	_, err = tx.ExecContext(ctx, query, quoteID, pq.Array(tags))
+
+	return err
+}
+
+// Setup our model
+type TagModel struct {
+	DB *sql.DB
+}
+
+// Get every tag that is in use and how many quotes use it. The most
+// used tags come first
+func (t TagModel) GetAll() ([]*Tag, error) {
+	query := `
+        SELECT tags.name, COUNT(quotes_tags.quote_id)
+        FROM tags
+        INNER JOIN quotes_tags ON quotes_tags.tag_id = tags.id
+        INNER JOIN quotes ON quotes.id = quotes_tags.quote_id
+        WHERE quotes.deleted_at IS NULL
+        GROUP BY tags.name
+        ORDER BY COUNT(quotes_tags.quote_id) DESC, tags.name ASC
+      `
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	rows, err := t.DB.QueryContext(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	tags := []*Tag{}
+	for rows.Next() {
+		var tag Tag
+		err := rows.Scan(&tag.Name, &tag.QuoteCount)
+		if err != nil {
+			return nil, err
+		}
+		tags = append(tags, &tag)
+	}
+	err = rows.Err()
+	if err != nil {
+		return nil, err
+	}
+
+	return tags, nil
+}
@@ -0,0 +1,151 @@
+package data
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// Get a specific quote from the trash
+func (c QuoteModel) GetDeleted(id int64) (*Quote, error) {
+	if id < 1 {
+		return nil, ErrRecordNotFound
+	}
+	query := `
+        SELECT ` + quoteColumns + `
+        FROM quotes
+        WHERE id = $1 AND deleted_at IS NOT NULL
+      `
+	var quote Quote
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	err := c.DB.QueryRowContext(ctx, query, id).Scan(quote.scanTargets()...)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrRecordNotFound
+		default:
+			return nil, err
+		}
+	}
+	return &quote, nil
+}
+
+// Get the quotes in the trash. With a createdBy of 0 we get everybody's
+// quotes, otherwise only the ones that user created
+func (c QuoteModel) GetAllDeleted(createdBy int64, filters Filters) ([]*Quote, Metadata, error) {
+	query := fmt.Sprintf(`
+        SELECT COUNT(*) OVER(), %s
+        FROM quotes
+        WHERE deleted_at IS NOT NULL
+        AND (created_by = $1 OR $1 = 0)
+        ORDER BY %s %s, id ASC
+        LIMIT $2 OFFSET $3`, quoteColumns, filters.sortColumn(), filters.sortDirection())
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	rows, err := c.DB.QueryContext(ctx, query, createdBy, filters.limit(), filters.offset())
+	if err != nil {
+		return nil, Metadata{}, err
+	}
+	defer rows.Close()
+
+	totalRecords := 0
+	quotes := []*Quote{}
+
+	for rows.Next() {
+		var quote Quote
+		err := rows.Scan(append([]any{&totalRecords}, quote.scanTargets()...)...)
+		if err != nil {
+			return nil, Metadata{}, err
+		}
+		quotes = append(quotes, &quote)
+	}
+	err = rows.Err()
+	if err != nil {
+		return nil, Metadata{}, err
+	}
+
+	metadata := calculateMetaData(totalRecords, filters.Page, filters.PageSize)
+
+	return quotes, metadata, nil
+}
+
+// Take a quote back out of the trash. The same editors as for Update()
+// may do this
+func (c QuoteModel) Restore(quote *Quote, editor QuoteEditor) error {
+	query := `
+        UPDATE quotes
+        SET deleted_at = NULL, updated_at = NOW()
+        WHERE id = $1 AND deleted_at IS NOT NULL
+        AND (created_by = $2 OR $3)
+        RETURNING updated_at
+      `
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	err := c.DB.QueryRowContext(ctx, query, quote.ID, editor.ID, editor.Moderator).Scan(&quote.UpdatedAt)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return quoteNotChanged(ctx, c.DB, quote.ID, true, editor)
+		default:
+			return err
+		}
+	}
+	quote.DeletedAt = nil
+
+	return nil
+}
+
+// Permanently delete a quote. Only quotes that are already in the
+// trash can be purged
+func (c QuoteModel) Purge(id int64) error {
+	if id < 1 {
+		return ErrRecordNotFound
+	}
+	query := `
+        DELETE FROM quotes
+        WHERE id = $1 AND deleted_at IS NOT NULL
+      `
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	result, err := c.DB.ExecContext(ctx, query, id)
+	if err != nil {
+		return err
+	}
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rowsAffected == 0 {
+		return ErrRecordNotFound
+	}
+
+	return nil
+}
+
+// Permanently delete every quote that went to the trash before the
+// cutoff. It returns how many quotes were purged
+func (c QuoteModel) PurgeDeletedBefore(cutoff time.Time) (int64, error) {
+	query := `
+        DELETE FROM quotes
+        WHERE deleted_at < $1
+      `
+	// there could be a lot of them so we give it more time
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	result, err := c.DB.ExecContext(ctx, query, cutoff)
+	if err != nil {
+		return 0, err
+	}
+
+	return result.RowsAffected()
+}
@@ -0,0 +1,293 @@
+package data
+
+import (
+	"context"
+	"crypto/rand"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"time"
+
+	"github.com/2016114132/qod/internal/validator"
+	"github.com/lib/pq"
+)
+
+// The events that a webhook can subscribe to. A quote is featured when
+// an editor schedules it as the quote of the day
+const QuoteFeatured = "quote.featured"
+
+var WebhookEvents = []string{QuoteCreated, QuoteUpdated, QuoteDeleted, QuoteFeatured}
+
+// A URL that we POST the quote events to. The secret signs every
+// delivery so the receiver can tell it came from us. It is only shown
+// when the webhook is created
+type Webhook struct {
+	ID        int64     `json:"id"`
+	UserID    int64     `json:"-"`
+	URL       string    `json:"url"`
+	Events    []string  `json:"events"`
+	Secret    string    `json:"secret,omitempty"`
+	Active    bool      `json:"active"`
+	CreatedAt time.Time `json:"created_at"`
+	Version   int32     `json:"version"`
+}
+
+func ValidateWebhook(v *validator.Validator, webhook *Webhook) {
+	v.Check(webhook.URL != "", "url", "must be provided")
+	v.Check(len(webhook.URL) <= 2048, "url", "must not be more than 2048 bytes long")
+	callback, err := url.Parse(webhook.URL)
+	isHTTPS := err == nil && callback.Scheme == "https" && callback.Host != ""
+	v.Check(isHTTPS, "url", "must be an https URL")
+
+	v.Check(len(webhook.Events) > 0, "events", "must contain at least one event")
+	v.Check(validator.Unique(webhook.Events), "events", "must not contain duplicate values")
+	for _, event := range webhook.Events {
+		v.Check(validator.PermittedValue(event, WebhookEvents...), "events",
+			fmt.Sprintf("must only contain %s, %s, %s or %s", QuoteCreated, QuoteUpdated, QuoteDeleted, QuoteFeatured))
+	}
+}
+
+// The worker POSTs to the webhooks from inside our network, so a
+// webhook must not point at us or at anything else on that network.
+// This looks the host up, so it is only run for a URL that is new: a
+// webhook whose host is gone can still be changed or turned off. The
+// addresses are checked again when the worker connects, since the name
+// can resolve to something else by then
+func ValidateWebhookHost(v *validator.Validator, webhook *Webhook) {
+	callback, err := url.Parse(webhook.URL)
+	// ValidateWebhook() reports a URL that is not https
+	if err != nil || callback.Scheme != "https" || callback.Host == "" {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	addresses, err := net.DefaultResolver.LookupIPAddr(ctx, callback.Hostname())
+	if err != nil || len(addresses) == 0 {
+		v.AddError("url", "must have a host that can be found")
+		return
+	}
+	for _, address := range addresses {
+		if !PublicIP(address.IP) {
+			v.AddError("url", "must not point to a private or local address")
+			return
+		}
+	}
+}
+
+// The ranges that are not public but that the net.IP methods don't know
+// about: "this network", carrier-grade NAT, benchmarking and NAT64 (which
+// can reach any IPv4 address, private ones too)
+var nonPublicNetworks = []*net.IPNet{
+	mustParseCIDR("0.0.0.0/8"),
+	mustParseCIDR("100.64.0.0/10"),
+	mustParseCIDR("198.18.0.0/15"),
+	mustParseCIDR("64:ff9b::/96"),
+}
+
+func mustParseCIDR(cidr string) *net.IPNet {
+	_, network, err := net.ParseCIDR(cidr)
+	if err != nil {
+		panic(err)
+	}
+	return network
+}
+
+// Is the address one that we may send webhooks to? Loopback, private,
+// link-local, unspecified and multicast addresses are not, and neither
+// are the nonPublicNetworks
+func PublicIP(ip net.IP) bool {
+	if ip.IsLoopback() || ip.IsPrivate() || ip.IsUnspecified() ||
+		ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() ||
+		ip.IsInterfaceLocalMulticast() || ip.IsMulticast() {
+		return false
+	}
+	for _, network := range nonPublicNetworks {
+		if network.Contains(ip) {
+			return false
+		}
+	}
+	return true
+}
+
+// The secret is only given when the webhook is created
+func ValidateWebhookSecret(v *validator.Validator, secret string) {
+	v.Check(len(secret) >= 16, "secret", "must be at least 16 bytes long")
+	v.Check(len(secret) <= 256, "secret", "must not be more than 256 bytes long")
+}
+
+// A random secret for a webhook that was created without one
+func GenerateWebhookSecret() (string, error) {
+	randomBytes := make([]byte, 32)
+	_, err := rand.Read(randomBytes)
+	if err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(randomBytes), nil
+}
+
+// The webhooks of a user. Users only ever see their own webhooks, the
+// webhooks of someone else are not found
+type WebhookModel struct {
+	DB *sql.DB
+}
+
+func (m WebhookModel) Insert(webhook *Webhook) error {
+	query := `
+        INSERT INTO webhooks (user_id, url, events, secret, active)
+        VALUES ($1, $2, $3, $4, $5)
+        RETURNING id, created_at, version
+        `
+	args := []any{webhook.UserID, webhook.URL, pq.Array(webhook.Events),
+		webhook.Secret, webhook.Active}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	return m.DB.QueryRowContext(ctx, query, args...).Scan(
+		&webhook.ID,
+		&webhook.CreatedAt,
+		&webhook.Version)
+}
+
+// Get a webhook of the user. The secret is left out
+func (m WebhookModel) Get(id int64, userID int64) (*Webhook, error) {
+	if id < 1 {
+		return nil, ErrRecordNotFound
+	}
+	query := `
+        SELECT id, user_id, url, events, active, created_at, version
+        FROM webhooks
+        WHERE id = $1 AND user_id = $2
+      `
+	var webhook Webhook
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	err := m.DB.QueryRowContext(ctx, query, id, userID).Scan(
+		&webhook.ID,
+		&webhook.UserID,
+		&webhook.URL,
+		pq.Array(&webhook.Events),
+		&webhook.Active,
+		&webhook.CreatedAt,
+		&webhook.Version,
+	)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrRecordNotFound
+		default:
+			return nil, err
+		}
+	}
+
+	return &webhook, nil
+}
+
+func (m WebhookModel) GetAllForUser(userID int64, filters Filters) ([]*Webhook, Metadata, error) {
+	query := fmt.Sprintf(`
+        SELECT COUNT(*) OVER(), id, user_id, url, events, active, created_at, version
+        FROM webhooks
+        WHERE user_id = $1
+        ORDER BY %s %s, id ASC
+        LIMIT $2 OFFSET $3`, filters.sortColumn(), filters.sortDirection())
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query, userID, filters.limit(), filters.offset())
+	if err != nil {
+		return nil, Metadata{}, err
+	}
+	defer rows.Close()
+
+	totalRecords := 0
+	webhooks := []*Webhook{}
+
+	for rows.Next() {
+		var webhook Webhook
+		err := rows.Scan(
+			&totalRecords,
+			&webhook.ID,
+			&webhook.UserID,
+			&webhook.URL,
+			pq.Array(&webhook.Events),
+			&webhook.Active,
+			&webhook.CreatedAt,
+			&webhook.Version,
+		)
+		if err != nil {
+			return nil, Metadata{}, err
+		}
+		webhooks = append(webhooks, &webhook)
+	}
+	err = rows.Err()
+	if err != nil {
+		return nil, Metadata{}, err
+	}
+
+	metadata := calculateMetaData(totalRecords, filters.Page, filters.PageSize)
+
+	return webhooks, metadata, nil
+}
+
+// Change the URL, the events or whether the webhook is active. The
+// secret stays the same
+func (m WebhookModel) Update(webhook *Webhook) error {
+	query := `
+        UPDATE webhooks
+        SET url = $1, events = $2, active = $3, version = version + 1
+        WHERE id = $4 AND user_id = $5 AND version = $6
+        RETURNING version
+        `
+	args := []any{webhook.URL, pq.Array(webhook.Events), webhook.Active,
+		webhook.ID, webhook.UserID, webhook.Version}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	err := m.DB.QueryRowContext(ctx, query, args...).Scan(&webhook.Version)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return ErrEditConflict
+		default:
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Remove a webhook of the user along with its deliveries
+func (m WebhookModel) Delete(id int64, userID int64) error {
+	if id < 1 {
+		return ErrRecordNotFound
+	}
+	query := `
+        DELETE FROM webhooks
+        WHERE id = $1 AND user_id = $2
+      `
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	result, err := m.DB.ExecContext(ctx, query, id, userID)
+	if err != nil {
+		return err
+	}
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rowsAffected == 0 {
+		return ErrRecordNotFound
+	}
+
+	return nil
+}
@@ -0,0 +1,7 @@
+CREATE TABLE IF NOT EXISTS quote_schedule (
+    id bigserial PRIMARY KEY,
+    created_at timestamp(0) WITH TIME ZONE NOT NULL DEFAULT NOW(),
+    scheduled_for date UNIQUE NOT NULL,
+    quote_id bigint NOT NULL REFERENCES quotes ON DELETE CASCADE,
+    version integer NOT NULL DEFAULT 1
+);
@@ -0,0 +1,4 @@
+CREATE TABLE IF NOT EXISTS tags (
+    id bigserial PRIMARY KEY,
+    name text UNIQUE NOT NULL
+);
@@ -0,0 +1 @@
+DROP TABLE IF EXISTS quotes_tags;
@@ -0,0 +1,11 @@
+CREATE TABLE IF NOT EXISTS authors (
+    id bigserial PRIMARY KEY,
+    created_at timestamp(0) WITH TIME ZONE NOT NULL DEFAULT NOW(),
+    name text NOT NULL,
+    slug text UNIQUE NOT NULL,
+    aliases text[] NOT NULL DEFAULT '{}',
+    bio text NOT NULL DEFAULT '',
+    birth_year integer,
+    death_year integer,
+    version integer NOT NULL DEFAULT 1
+);
@@ -0,0 +1,24 @@
+ALTER TABLE quotes
+ADD COLUMN IF NOT EXISTS author_id bigint REFERENCES authors ON DELETE SET NULL;
+
+CREATE INDEX IF NOT EXISTS quotes_author_id_idx ON quotes (author_id);
+
+-- Create an author for every distinct author name we already have. Names
+-- that only differ by case, spaces or punctuation share a slug, so they
+-- become the same author
+INSERT INTO authors (name, slug)
+SELECT MIN(trim(author)), slug
+FROM (
+    SELECT author,
+           trim(both '-' FROM regexp_replace(lower(author), '[^[:alnum:]]+', '-', 'g')) AS slug
+    FROM quotes
+) AS quote_authors
+WHERE slug <> ''
+GROUP BY slug
+ON CONFLICT (slug) DO NOTHING;
+
+-- Link the quotes to their authors
+UPDATE quotes
+SET author_id = authors.id
+FROM authors
+WHERE authors.slug = trim(both '-' FROM regexp_replace(lower(quotes.author), '[^[:alnum:]]+', '-', 'g'));
@@ -0,0 +1,2 @@
+ALTER TABLE quotes
+ADD COLUMN IF NOT EXISTS created_by bigint REFERENCES users ON DELETE SET NULL;
@@ -0,0 +1,5 @@
+ALTER TABLE quotes
+ADD COLUMN IF NOT EXISTS updated_at timestamp(0) WITH TIME ZONE NOT NULL DEFAULT NOW();
+
+-- the existing quotes were last changed when they were created, as far as we know
+UPDATE quotes SET updated_at = created_at;
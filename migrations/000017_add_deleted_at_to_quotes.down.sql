@@ -0,0 +1 @@
+ALTER TABLE quotes DROP COLUMN IF EXISTS deleted_at;
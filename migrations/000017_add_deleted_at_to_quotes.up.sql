@@ -0,0 +1,6 @@
+ALTER TABLE quotes
+ADD COLUMN IF NOT EXISTS deleted_at timestamp(0) WITH TIME ZONE;
+
+-- the trash and the purge only look at the deleted quotes
+CREATE INDEX IF NOT EXISTS quotes_deleted_at_idx ON quotes (deleted_at)
+WHERE deleted_at IS NOT NULL;
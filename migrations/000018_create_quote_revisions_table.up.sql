@@ -0,0 +1,15 @@
+CREATE TABLE IF NOT EXISTS quote_revisions (
+    id bigserial PRIMARY KEY,
+    quote_id bigint NOT NULL REFERENCES quotes ON DELETE CASCADE,
+    version integer NOT NULL,
+    content text NOT NULL,
+    author text NOT NULL,
+    editor_id bigint REFERENCES users ON DELETE SET NULL,
+    created_at timestamp(0) WITH TIME ZONE NOT NULL DEFAULT NOW(),
+    UNIQUE (quote_id, version)
+);
+
+-- the history of the existing quotes starts with their current version
+INSERT INTO quote_revisions (quote_id, version, content, author, editor_id, created_at)
+SELECT id, version, content, author, created_by, updated_at
+FROM quotes;
@@ -0,0 +1 @@
+DROP INDEX IF EXISTS quotes_author_keyset_idx;
@@ -0,0 +1,3 @@
+-- Cursor pagination reads the quotes in (sort column, id) order starting
+-- after a given row. The id sorts use the primary key
+CREATE INDEX IF NOT EXISTS quotes_author_keyset_idx ON quotes (author, id) WHERE deleted_at IS NULL;
@@ -0,0 +1 @@
+DROP INDEX IF EXISTS quotes_created_at_keyset_idx;
@@ -0,0 +1,2 @@
+-- Sorting and filtering the quote listing by creation time
+CREATE INDEX IF NOT EXISTS quotes_created_at_keyset_idx ON quotes (created_at, id) WHERE deleted_at IS NULL;
@@ -0,0 +1,2 @@
+ALTER TABLE quotes DROP COLUMN IF EXISTS search_simple;
+ALTER TABLE quotes DROP COLUMN IF EXISTS search_english;
@@ -0,0 +1,15 @@
+-- The search vectors are kept up to date by PostgreSQL itself. There is
+-- one per text search configuration that /v1/search supports. Matches in
+-- the content (A) rank higher than matches in the author (B)
+ALTER TABLE quotes
+ADD COLUMN IF NOT EXISTS search_english tsvector GENERATED ALWAYS AS (
+    setweight(to_tsvector('english', content), 'A') ||
+    setweight(to_tsvector('english', author), 'B')) STORED;
+
+ALTER TABLE quotes
+ADD COLUMN IF NOT EXISTS search_simple tsvector GENERATED ALWAYS AS (
+    setweight(to_tsvector('simple', content), 'A') ||
+    setweight(to_tsvector('simple', author), 'B')) STORED;
+
+CREATE INDEX IF NOT EXISTS quotes_search_english_idx ON quotes USING GIN (search_english);
+CREATE INDEX IF NOT EXISTS quotes_search_simple_idx ON quotes USING GIN (search_simple);
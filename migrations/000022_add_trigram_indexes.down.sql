@@ -0,0 +1,4 @@
+DROP INDEX IF EXISTS authors_name_trgm_idx;
+DROP INDEX IF EXISTS quotes_content_trgm_idx;
+DROP INDEX IF EXISTS quotes_author_trgm_idx;
+DROP EXTENSION IF EXISTS pg_trgm;
@@ -0,0 +1,6 @@
+-- Trigrams let us find "Albert Einstein" when someone types "Einstien"
+CREATE EXTENSION IF NOT EXISTS pg_trgm;
+
+CREATE INDEX IF NOT EXISTS quotes_author_trgm_idx ON quotes USING GIN (author gin_trgm_ops);
+CREATE INDEX IF NOT EXISTS quotes_content_trgm_idx ON quotes USING GIN (content gin_trgm_ops);
+CREATE INDEX IF NOT EXISTS authors_name_trgm_idx ON authors USING GIN (name gin_trgm_ops);
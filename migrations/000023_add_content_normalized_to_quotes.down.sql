@@ -0,0 +1,2 @@
+ALTER TABLE quotes DROP COLUMN IF EXISTS content_normalized;
+DROP FUNCTION IF EXISTS normalize_quote_content(text);
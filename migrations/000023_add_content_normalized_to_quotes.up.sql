@@ -0,0 +1,17 @@
+-- Two quotes are the same if they only differ in case, whitespace or
+-- punctuation (straight or curly quotes included): "Don’t panic!" and
+-- "dont  panic" both become "dont panic"
+CREATE OR REPLACE FUNCTION normalize_quote_content(content text) RETURNS text
+LANGUAGE sql IMMUTABLE PARALLEL SAFE AS $$
+    SELECT trim(regexp_replace(
+        regexp_replace(lower(content), '[^[:alnum:][:space:]]+', '', 'g'),
+        '[[:space:]]+', ' ', 'g'))
+$$;
+
+ALTER TABLE quotes
+ADD COLUMN IF NOT EXISTS content_normalized text
+GENERATED ALWAYS AS (normalize_quote_content(content)) STORED;
+
+-- exact duplicates use the btree index, near duplicates the trigram one
+CREATE INDEX IF NOT EXISTS quotes_content_normalized_idx ON quotes (content_normalized);
+CREATE INDEX IF NOT EXISTS quotes_content_normalized_trgm_idx ON quotes USING GIN (content_normalized gin_trgm_ops);
@@ -0,0 +1,3 @@
+DROP TRIGGER IF EXISTS quote_events_trigger ON quotes;
+DROP FUNCTION IF EXISTS record_quote_event();
+DROP TABLE IF EXISTS quote_events;
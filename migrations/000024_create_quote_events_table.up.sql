@@ -0,0 +1,44 @@
+-- Every change to a quote is recorded as an event and announced on the
+-- quote_events channel (LISTEN/NOTIFY), so every instance of the API can
+-- stream it. The events are kept for a while so clients can resume
+CREATE TABLE IF NOT EXISTS quote_events (
+    id bigserial PRIMARY KEY,
+    quote_id bigint NOT NULL,
+    type text NOT NULL,
+    created_at timestamp(0) with time zone NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS quote_events_created_at_idx ON quote_events (created_at);
+
+CREATE OR REPLACE FUNCTION record_quote_event() RETURNS trigger
+LANGUAGE plpgsql AS $$
+DECLARE
+    event_type text;
+    event_id bigint;
+BEGIN
+    IF TG_OP = 'INSERT' THEN
+        event_type := 'quote.created';
+    ELSIF OLD.deleted_at IS NULL AND NEW.deleted_at IS NOT NULL THEN
+        event_type := 'quote.deleted';
+    ELSIF OLD.deleted_at IS NOT NULL AND NEW.deleted_at IS NULL THEN
+        -- a quote back from the trash is new again to the clients
+        event_type := 'quote.created';
+    ELSIF NEW.deleted_at IS NOT NULL THEN
+        -- nobody sees the quotes in the trash
+        RETURN NULL;
+    ELSE
+        event_type := 'quote.updated';
+    END IF;
+
+    INSERT INTO quote_events (quote_id, type)
+    VALUES (NEW.id, event_type)
+    RETURNING id INTO event_id;
+
+    PERFORM pg_notify('quote_events', event_id::text);
+    RETURN NULL;
+END;
+$$;
+
+CREATE TRIGGER quote_events_trigger
+AFTER INSERT OR UPDATE ON quotes
+FOR EACH ROW EXECUTE FUNCTION record_quote_event();
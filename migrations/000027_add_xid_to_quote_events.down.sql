@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS quote_events_xid_id_idx;
+ALTER TABLE quote_events DROP COLUMN IF EXISTS xid;
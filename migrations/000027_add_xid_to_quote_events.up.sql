@@ -0,0 +1,11 @@
+-- The id of an event is taken when it is inserted, but the event is only
+-- seen once its transaction commits, so the ids don't show up in order.
+-- The events are read in the order of their transactions instead, and
+-- only those of transactions older than every transaction still going
+-- on, so that no event can turn up behind a reader later. xid8 and
+-- pg_current_snapshot() need PostgreSQL 13 or later. Any long transaction
+-- on the database holds back the events until it ends, so keep them short
+ALTER TABLE quote_events
+ADD COLUMN IF NOT EXISTS xid xid8 NOT NULL DEFAULT pg_current_xact_id();
+
+CREATE INDEX IF NOT EXISTS quote_events_xid_id_idx ON quote_events (xid, id);
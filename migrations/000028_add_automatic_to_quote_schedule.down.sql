@@ -0,0 +1,2 @@
+DELETE FROM quote_schedule WHERE automatic;
+ALTER TABLE quote_schedule DROP COLUMN IF EXISTS automatic;
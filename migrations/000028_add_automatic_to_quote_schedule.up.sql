@@ -0,0 +1,5 @@
+-- The automatic quote of today is saved in the schedule by a background
+-- job, so that adding or removing quotes later doesn't change it.
+-- automatic tells those apart from the editors' picks
+ALTER TABLE quote_schedule
+ADD COLUMN IF NOT EXISTS automatic boolean NOT NULL DEFAULT false;